@@ -1,34 +1,223 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type ServerConf struct {
-	Host string `yaml:"host"`
-	Port int    `yaml:"port"`
+	Host            string        `yaml:"host"`
+	Port            int           `yaml:"port"`
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// DrainDelay is how long /readyz fails before server stops accepting
+	// requests on shutdown.
+	DrainDelay time.Duration `yaml:"drain_delay"`
+	TLS        TLSConf       `yaml:"tls"`
+}
+
+// TLSConf enables HTTPS. Certificate and client CA files are reloaded when
+// they change. ClientAuth is mode of mutual TLS: off, optional or
+// required, client certificates are verified against ClientCA files.
+// MinVersion is 1.2 or 1.3, CipherSuites are names of TLS 1.2 suites as
+// in crypto/tls.
+type TLSConf struct {
+	Enabled      bool     `yaml:"enabled"`
+	CertFile     string   `yaml:"cert_file"`
+	KeyFile      string   `yaml:"key_file"`
+	ClientAuth   string   `yaml:"client_auth"`
+	ClientCA     []string `yaml:"client_ca"`
+	MinVersion   string   `yaml:"min_version"`
+	CipherSuites []string `yaml:"cipher_suites"`
 }
 type SuperuserConf struct {
 	Email    string `yaml:"email"`
 	Username string `yaml:"username"`
-	Password string `yaml:"password"`
+	Password Secret `yaml:"password"`
 	Admin    bool   `yaml:"admin"`
 }
 
+type ImpersonationConf struct {
+	Key Secret        `yaml:"key"`
+	TTL time.Duration `yaml:"ttl"`
+}
+
+type AuditConf struct {
+	Sink string `yaml:"sink"` // "storage" or "file"
+	Path string `yaml:"path"`
+}
+
+type WebhooksConf struct {
+	PollInterval time.Duration `yaml:"poll_interval"`
+	Timeout      time.Duration `yaml:"timeout"`
+	MaxAttempts  int           `yaml:"max_attempts"`
+	Backoff      time.Duration `yaml:"backoff"`
+	MaxBackoff   time.Duration `yaml:"max_backoff"`
+}
+
+type EventsConf struct {
+	BufferSize int `yaml:"buffer_size"`
+}
+
+type GRPCConf struct {
+	Enabled bool   `yaml:"enabled"`
+	Host    string `yaml:"host"`
+	Port    int    `yaml:"port"`
+}
+
+type GraphQLConf struct {
+	MaxDepth      int `yaml:"max_depth"`
+	MaxComplexity int `yaml:"max_complexity"`
+}
+
+// APIConf describes deprecation of unversioned routes kept as aliases of /v1.
+type APIConf struct {
+	DeprecatedSince time.Time `yaml:"deprecated_since"`
+	Sunset          time.Time `yaml:"sunset"`
+}
+
+// IdempotencyConf sets how long responses of requests with
+// Idempotency-Key are replayed.
+type IdempotencyConf struct {
+	TTL time.Duration `yaml:"ttl"`
+}
+
+// ExportConf grants include_hashes permission of user export
+// to admins listed by user id.
+type ExportConf struct {
+	IncludeHashes []string `yaml:"include_hashes"`
+}
+
+// CertAuthConf maps verified mTLS client certificates to accounts, so
+// services authenticate without password. Requires server.tls with
+// client_auth. The first matching rule wins.
+type CertAuthConf struct {
+	Rules []CertRuleConf `yaml:"rules"`
+}
+
+// CertRuleConf matches Field of certificate, subject CN (cn) or URI SAN
+// (uri), against regular expression Match, which must match whole value.
+// Username of account is Username
+// with submatches expanded ($1), empty Username means whole value.
+type CertRuleConf struct {
+	Field    string `yaml:"field"`
+	Match    string `yaml:"match"`
+	Username string `yaml:"username"`
+}
+
+// MetricsConf enables Prometheus /metrics. It is served on separate admin
+// listener when Port is set, otherwise on main HTTP port.
+type MetricsConf struct {
+	Enabled bool   `yaml:"enabled"`
+	Host    string `yaml:"host"`
+	Port    int    `yaml:"port"`
+}
+
+// TracingConf enables export of OpenTelemetry spans to OTLP/HTTP collector
+// at Endpoint (host:port). SampleRatio is share of traced requests started
+// by the service: 1 traces all, 0 none. Unset value defaults to 1.
+type TracingConf struct {
+	Enabled     bool    `yaml:"enabled"`
+	Endpoint    string  `yaml:"endpoint"`
+	Insecure    bool    `yaml:"insecure"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// LogConf sets level (debug, info, warn, error) and format (json, text)
+// of service logs.
+type LogConf struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
 type Config struct {
-	Server ServerConf    `yaml:"server"`
-	Admin  SuperuserConf `yaml:"superuser"`
+	Log           LogConf           `yaml:"log"`
+	Server        ServerConf        `yaml:"server"`
+	Metrics       MetricsConf       `yaml:"metrics"`
+	Tracing       TracingConf       `yaml:"tracing"`
+	GRPC          GRPCConf          `yaml:"grpc"`
+	Admin         SuperuserConf     `yaml:"superuser"`
+	Impersonation ImpersonationConf `yaml:"impersonation"`
+	Audit         AuditConf         `yaml:"audit"`
+	Webhooks      WebhooksConf      `yaml:"webhooks"`
+	Events        EventsConf        `yaml:"events"`
+	GraphQL       GraphQLConf       `yaml:"graphql"`
+	API           APIConf           `yaml:"api"`
+	Idempotency   IdempotencyConf   `yaml:"idempotency"`
+	Export        ExportConf        `yaml:"export"`
+	CertAuth      CertAuthConf      `yaml:"cert_auth"`
+}
+
+// Redacted is printed instead of values of secrets.
+const Redacted = "[REDACTED]"
+
+// Default returns config used for fields missing in file, environment
+// and flags.
+func Default() Config {
+	return Config{
+		Log: LogConf{Level: "info", Format: "json"},
+		Server: ServerConf{
+			Host:            "localhost",
+			Port:            8080,
+			ReadTimeout:     5 * time.Second,
+			WriteTimeout:    5 * time.Second,
+			ShutdownTimeout: 10 * time.Second,
+			TLS:             TLSConf{ClientAuth: "off", MinVersion: "1.2"},
+		},
+		Tracing: TracingConf{SampleRatio: 1},
+		GRPC:    GRPCConf{Host: "localhost", Port: 9090},
+		Admin:   SuperuserConf{Username: "admin", Admin: true},
+		Impersonation: ImpersonationConf{
+			TTL: 15 * time.Minute,
+		},
+		Audit: AuditConf{Sink: "storage", Path: "audit.log"},
+		Webhooks: WebhooksConf{
+			PollInterval: time.Second,
+			Timeout:      5 * time.Second,
+			MaxAttempts:  8,
+			Backoff:      time.Second,
+			MaxBackoff:   10 * time.Minute,
+		},
+		Events:      EventsConf{BufferSize: 1024},
+		GraphQL:     GraphQLConf{MaxDepth: 8, MaxComplexity: 200},
+		Idempotency: IdempotencyConf{TTL: 24 * time.Hour},
+	}
+}
+
+// Resolve returns effective config. Values are taken from, in order of
+// precedence, set flags, environment, file at path and Default. Missing
+// file is treated as empty, so service may be configured by environment
+// and flags only. Flags may be nil, lookup is usually os.LookupEnv.
+func Resolve(path string, lookup func(string) (string, bool), flags *Flags) (Config, error) {
+	conf := Default()
+
+	if err := Load(path, &conf); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return Config{}, err
+	}
+
+	if err := ApplyEnv(&conf, lookup); err != nil {
+		return Config{}, err
+	}
+
+	if err := flags.Apply(&conf); err != nil {
+		return Config{}, err
+	}
+
+	return conf, nil
 }
 
 // Load app config. Requires path to yaml config file
 func Load(path string, conf *Config) error {
 	file, err := os.Open(path)
 	if err != nil {
-		return fmt.Errorf("failed to open config file: %v", err)
+		return fmt.Errorf("failed to open config file: %w", err)
 	}
 
 	data, err := io.ReadAll(file)
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	return path
+}
+
+func TestResolve(t *testing.T) {
+	path := writeConfig(t, `
+server:
+  port: 8081
+  read_timeout: 7s
+superuser:
+  password: "from-file"
+export:
+  include_hashes: [migrator]
+`)
+
+	tests := []struct {
+		name    string
+		path    string
+		env     map[string]string
+		args    []string
+		check   func(t *testing.T, c Config)
+		wantErr bool
+	}{
+		{
+			name: "file over defaults",
+			check: func(t *testing.T, c Config) {
+				if c.Server.Port != 8081 || c.Server.ReadTimeout != 7*time.Second || c.Server.WriteTimeout != 5*time.Second {
+					t.Errorf("server = %+v", c.Server)
+				}
+			},
+		},
+		{
+			name: "env over file",
+			env: map[string]string{
+				"ACCOUNT_MASTER_SERVER_PORT":           "9000",
+				"ACCOUNT_MASTER_SUPERUSER_PASSWORD":    "from-env",
+				"ACCOUNT_MASTER_EXPORT_INCLUDE_HASHES": "a, b",
+				"ACCOUNT_MASTER_API_SUNSET":            "2027-04-19",
+				"ACCOUNT_MASTER_GRPC_ENABLED":          "false",
+			},
+			check: func(t *testing.T, c Config) {
+				if c.Server.Port != 9000 || c.Admin.Password.Value() != "from-env" || c.GRPC.Enabled {
+					t.Errorf("config = %+v", c)
+				}
+
+				if !reflect.DeepEqual(c.Export.IncludeHashes, []string{"a", "b"}) {
+					t.Errorf("include_hashes = %q", c.Export.IncludeHashes)
+				}
+
+				if want := time.Date(2027, 4, 19, 0, 0, 0, 0, time.UTC); !c.API.Sunset.Equal(want) {
+					t.Errorf("sunset = %v, want %v", c.API.Sunset, want)
+				}
+			},
+		},
+		{
+			name: "flag over env",
+			env:  map[string]string{"ACCOUNT_MASTER_SERVER_PORT": "9000"},
+			args: []string{"-server.port=9100", "-tracing.sample_ratio=0.5"},
+			check: func(t *testing.T, c Config) {
+				if c.Server.Port != 9100 || c.Tracing.SampleRatio != 0.5 {
+					t.Errorf("config = %+v", c)
+				}
+			},
+		},
+		{
+			name: "zero sample ratio is kept",
+			args: []string{"-tracing.sample_ratio=0"},
+			check: func(t *testing.T, c Config) {
+				if c.Tracing.SampleRatio != 0 {
+					t.Errorf("sample_ratio = %g, want 0", c.Tracing.SampleRatio)
+				}
+			},
+		},
+		{
+			name: "missing file",
+			path: filepath.Join(t.TempDir(), "missing.yaml"),
+			env:  map[string]string{"ACCOUNT_MASTER_SERVER_PORT": "9000"},
+			check: func(t *testing.T, c Config) {
+				if c.Server.Port != 9000 || c.Server.ReadTimeout != Default().Server.ReadTimeout {
+					t.Errorf("server = %+v", c.Server)
+				}
+			},
+		},
+		{
+			name:    "config path is directory",
+			path:    t.TempDir(),
+			wantErr: true,
+		},
+		{
+			name:    "invalid env",
+			env:     map[string]string{"ACCOUNT_MASTER_SERVER_READ_TIMEOUT": "soon"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid flag",
+			args:    []string{"-server.port=http"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			flags := NewFlags(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			lookup := func(key string) (string, bool) {
+				v, ok := tt.env[key]
+				return v, ok
+			}
+
+			p := path
+			if tt.path != "" {
+				p = tt.path
+			}
+
+			c, err := Resolve(p, lookup, flags)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.check != nil {
+				tt.check(t, c)
+			}
+		})
+	}
+}
+
+// TestOverrides sets every advertised flag and environment variable.
+func TestOverrides(t *testing.T) {
+	samples := make(map[string]string)
+	for _, f := range fields(&Config{}) {
+		switch t := f.value.Type(); {
+		case t == durationType:
+			samples[f.path] = "1s"
+		case t == timeType:
+			samples[f.path] = "2027-04-19"
+		case t.Kind() == reflect.Int:
+			samples[f.path] = "1"
+		case t.Kind() == reflect.Bool:
+			samples[f.path] = "true"
+		case t.Kind() == reflect.Float64:
+			samples[f.path] = "0.5"
+		default:
+			samples[f.path] = "a,b"
+		}
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := NewFlags(fs)
+
+	var args []string
+	env := make(map[string]string)
+	fs.VisitAll(func(fl *flag.Flag) {
+		args = append(args, "-"+fl.Name+"="+samples[fl.Name])
+		env[EnvName(fl.Name)] = samples[fl.Name]
+	})
+
+	if fs.Lookup("cert_auth.rules") != nil {
+		t.Error("flag registered for cert_auth.rules")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	c := Default()
+	if err := flags.Apply(&c); err != nil {
+		t.Errorf("Flags.Apply() error = %v", err)
+	}
+
+	c = Default()
+	lookup := func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+	if err := ApplyEnv(&c, lookup); err != nil {
+		t.Errorf("ApplyEnv() error = %v", err)
+	}
+}
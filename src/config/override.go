@@ -0,0 +1,200 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix of environment variables overriding config fields. Variable
+// name is prefix plus yaml path of field in upper case with dots replaced
+// by underscores, e.g. ACCOUNT_MASTER_SERVER_PORT for server.port.
+const EnvPrefix = "ACCOUNT_MASTER_"
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+	secretType   = reflect.TypeOf(Secret{})
+)
+
+// field is leaf config value addressed by yaml path.
+type field struct {
+	path  string
+	value reflect.Value
+}
+
+// fields lists leaf fields of conf in declaration order.
+func fields(conf *Config) []field {
+	var out []field
+	walk(reflect.ValueOf(conf).Elem(), "", &out)
+	return out
+}
+
+func walk(v reflect.Value, prefix string, out *[]field) {
+	t := v.Type()
+	for i := range t.NumField() {
+		sf := t.Field(i)
+
+		name, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct && fv.Type() != timeType && fv.Type() != secretType {
+			walk(fv, path, out)
+			continue
+		}
+
+		*out = append(*out, field{path: path, value: fv})
+	}
+}
+
+// overrides lists fields of conf settable from a single string. Lists of
+// sections, such as cert_auth.rules, are configured in file only.
+func overrides(conf *Config) []field {
+	var out []field
+	for _, f := range fields(conf) {
+		if t := f.value.Type(); t.Kind() != reflect.Slice || t.Elem().Kind() == reflect.String {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// EnvName returns environment variable overriding field at path.
+func EnvName(path string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+}
+
+// ApplyEnv overrides fields of conf from environment, see EnvPrefix.
+// Lookup is usually os.LookupEnv.
+func ApplyEnv(conf *Config, lookup func(string) (string, bool)) error {
+	for _, f := range overrides(conf) {
+		s, ok := lookup(EnvName(f.path))
+		if !ok {
+			continue
+		}
+
+		if err := setValue(f.value, s); err != nil {
+			return fmt.Errorf("invalid %s: %w", EnvName(f.path), err)
+		}
+	}
+
+	return nil
+}
+
+// Flags overrides config fields from command line. Flag of every field
+// is named by its yaml path, e.g. -server.port. Only flags set on
+// command line are applied.
+type Flags struct {
+	set map[string]string
+}
+
+// NewFlags registers flag of every config field on fs.
+func NewFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{set: make(map[string]string)}
+
+	for _, fl := range overrides(&Config{}) {
+		path := fl.path
+		fs.Func(path, "overrides "+path+", same as "+EnvName(path), func(s string) error {
+			f.set[path] = s
+			return nil
+		})
+	}
+
+	return f
+}
+
+// Apply overrides fields of conf with values of set flags.
+func (f *Flags) Apply(conf *Config) error {
+	if f == nil {
+		return nil
+	}
+
+	for _, fl := range overrides(conf) {
+		s, ok := f.set[fl.path]
+		if !ok {
+			continue
+		}
+
+		if err := setValue(fl.value, s); err != nil {
+			return fmt.Errorf("invalid -%s: %w", fl.path, err)
+		}
+	}
+
+	return nil
+}
+
+// setValue parses s into config field v.
+func setValue(v reflect.Value, s string) error {
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Type() == secretType:
+		secret, err := ResolveSecret(s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(secret))
+	case v.Type() == timeType:
+		t, err := parseTime(s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+// parseTime accepts RFC 3339 time or date, same as yaml.
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.DateOnly, s)
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"reflect"
+	"time"
+)
+
+// reloadable lists yaml paths of fields applied by running service without
+// restart, see Config.Reload.
+var reloadable = map[string]bool{
+	"log.level":              true,
+	"graphql.max_depth":      true,
+	"graphql.max_complexity": true,
+	"api.deprecated_since":   true,
+	"api.sunset":             true,
+	"idempotency.ttl":        true,
+	"export.include_hashes":  true,
+}
+
+// Reload returns c with reloadable fields taken from next. Changed lists
+// applied fields, rejected lists changed fields requiring restart, which
+// keep values of c. Both are yaml paths in declaration order.
+func (c Config) Reload(next Config) (out Config, changed, rejected []string) {
+	out = c
+
+	cur, upd := fields(&out), fields(&next)
+	for i, f := range cur {
+		if equal(f.value, upd[i].value) {
+			continue
+		}
+
+		if !reloadable[f.path] {
+			rejected = append(rejected, f.path)
+			continue
+		}
+
+		f.value.Set(upd[i].value)
+		changed = append(changed, f.path)
+	}
+
+	return out, changed, rejected
+}
+
+func equal(a, b reflect.Value) bool {
+	if a.Type() == timeType {
+		return a.Interface().(time.Time).Equal(b.Interface().(time.Time))
+	}
+
+	if a.Kind() == reflect.Slice && a.Len() == 0 && b.Len() == 0 {
+		return true
+	}
+
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConfig_Reload(t *testing.T) {
+	cur := Default()
+	cur.Admin.Password = NewSecret("old")
+
+	tests := []struct {
+		name         string
+		update       func(c *Config)
+		wantChanged  []string
+		wantRejected []string
+		check        func(t *testing.T, c Config)
+	}{
+		{
+			name: "unchanged",
+			update: func(c *Config) {
+				c.Export.IncludeHashes = []string{}
+			},
+		},
+		{
+			name: "reloadable",
+			update: func(c *Config) {
+				c.Log.Level = "debug"
+				c.GraphQL.MaxDepth = 4
+				c.Export.IncludeHashes = []string{"migrator"}
+				c.API.Sunset = time.Date(2027, 4, 19, 0, 0, 0, 0, time.UTC)
+			},
+			wantChanged: []string{"log.level", "graphql.max_depth", "api.sunset", "export.include_hashes"},
+			check: func(t *testing.T, c Config) {
+				if c.Log.Level != "debug" || c.GraphQL.MaxDepth != 4 || len(c.Export.IncludeHashes) != 1 {
+					t.Errorf("config = %+v", c)
+				}
+			},
+		},
+		{
+			name: "requires restart",
+			update: func(c *Config) {
+				c.Server.Port = 9000
+				c.Admin.Password = NewSecret("new")
+				c.Idempotency.TTL = time.Hour
+			},
+			wantChanged:  []string{"idempotency.ttl"},
+			wantRejected: []string{"server.port", "superuser.password"},
+			check: func(t *testing.T, c Config) {
+				if c.Server.Port != 8080 || c.Admin.Password.Value() != "old" || c.Idempotency.TTL != time.Hour {
+					t.Errorf("config = %+v", c)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := cur
+			tt.update(&next)
+
+			got, changed, rejected := cur.Reload(next)
+			if !reflect.DeepEqual(changed, tt.wantChanged) {
+				t.Errorf("changed = %q, want %q", changed, tt.wantChanged)
+			}
+			if !reflect.DeepEqual(rejected, tt.wantRejected) {
+				t.Errorf("rejected = %q, want %q", rejected, tt.wantRejected)
+			}
+
+			if tt.check != nil {
+				tt.check(t, got)
+			}
+		})
+	}
+}
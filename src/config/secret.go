@@ -0,0 +1,101 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Reference prefixes of secret values.
+const (
+	fileRef = "file://"
+	envRef  = "env:"
+)
+
+// Secret is sensitive config value. It redacts itself when formatted,
+// logged or marshaled, so only Value reveals it.
+//
+// In file, environment and flags secret is given either literally or as
+// reference resolved on load: file:///run/secrets/admin_pw reads file
+// (trailing newline is trimmed), env:VAR reads environment variable.
+type Secret struct {
+	value string
+}
+
+// NewSecret returns secret holding value as is.
+func NewSecret(value string) Secret {
+	return Secret{value: value}
+}
+
+// ResolveSecret returns secret of literal value or reference.
+func ResolveSecret(s string) (Secret, error) {
+	switch {
+	case strings.HasPrefix(s, fileRef):
+		data, err := os.ReadFile(strings.TrimPrefix(s, fileRef))
+		if err != nil {
+			return Secret{}, fmt.Errorf("failed to read secret: %w", err)
+		}
+		return Secret{value: strings.TrimRight(string(data), "\r\n")}, nil
+	case strings.HasPrefix(s, envRef):
+		name := strings.TrimPrefix(s, envRef)
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return Secret{}, fmt.Errorf("secret variable %s is not set", name)
+		}
+		return Secret{value: v}, nil
+	default:
+		return Secret{value: s}, nil
+	}
+}
+
+// Value returns secret itself.
+func (s Secret) Value() string {
+	return s.value
+}
+
+// String returns Redacted, or empty string for empty secret.
+func (s Secret) String() string {
+	if s.value == "" {
+		return ""
+	}
+
+	return Redacted
+}
+
+// Format redacts secret for every verb, including %#v and %x.
+func (s Secret) Format(f fmt.State, _ rune) {
+	_, _ = io.WriteString(f, s.String())
+}
+
+// LogValue implements slog.LogValuer.
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s Secret) MarshalYAML() (any, error) {
+	return s.String(), nil
+}
+
+// UnmarshalYAML resolves secret reference from config file.
+func (s *Secret) UnmarshalYAML(node *yaml.Node) error {
+	var raw string
+	if err := node.Decode(&raw); err != nil {
+		return err
+	}
+
+	resolved, err := ResolveSecret(raw)
+	if err != nil {
+		return err
+	}
+
+	*s = resolved
+	return nil
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestResolveSecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "admin_pw")
+	if err := os.WriteFile(path, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	t.Setenv("TEST_ADMIN_PW", "from-env")
+
+	tests := []struct {
+		name    string
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{name: "literal", ref: "plain", want: "plain"},
+		{name: "file", ref: "file://" + path, want: "from-file"},
+		{name: "env", ref: "env:TEST_ADMIN_PW", want: "from-env"},
+		{name: "missing file", ref: "file://" + path + ".missing", wantErr: true},
+		{name: "unset env", ref: "env:TEST_UNSET_SECRET", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveSecret(tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveSecret() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got.Value() != tt.want {
+				t.Errorf("Value() = %q, want %q", got.Value(), tt.want)
+			}
+		})
+	}
+}
+
+func TestResolve_SecretReference(t *testing.T) {
+	t.Setenv("TEST_ADMIN_PW", "from-env")
+	t.Setenv("ACCOUNT_MASTER_IMPERSONATION_KEY", "env:TEST_ADMIN_PW")
+
+	path := writeConfig(t, "superuser:\n  password: env:TEST_ADMIN_PW\n")
+
+	c, err := Resolve(path, os.LookupEnv, nil)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	if c.Admin.Password.Value() != "from-env" || c.Impersonation.Key.Value() != "from-env" {
+		t.Errorf("secrets are not resolved")
+	}
+}
+
+func TestSecret_Redacts(t *testing.T) {
+	c := Default()
+	c.Admin.Password = NewSecret("hunter2")
+
+	var logged bytes.Buffer
+	slog.New(slog.NewJSONHandler(&logged, nil)).Info("config", "password", c.Admin.Password)
+
+	jsonOut, _ := json.Marshal(c.Admin)
+	yamlOut, _ := yaml.Marshal(c)
+
+	outputs := map[string]string{
+		"%v":   fmt.Sprintf("%v", c.Admin),
+		"%+v":  fmt.Sprintf("%+v", c.Admin),
+		"%#v":  fmt.Sprintf("%#v", c.Admin),
+		"%s":   fmt.Sprintf("%s", c.Admin.Password),
+		"%x":   fmt.Sprintf("%x", c.Admin.Password),
+		"slog": logged.String(),
+		"json": string(jsonOut),
+		"yaml": string(yamlOut),
+	}
+
+	for name, out := range outputs {
+		if strings.Contains(out, "hunter2") || strings.Contains(out, fmt.Sprintf("%x", "hunter2")) {
+			t.Errorf("%s output reveals secret: %s", name, out)
+		}
+
+		if !strings.Contains(out, Redacted) {
+			t.Errorf("%s output is not redacted: %s", name, out)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lekht/account-master/src/pkg/server"
+)
+
+// FieldError is problem of single config field addressed by yaml path.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError lists every problem found by Config.Validate.
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	lines := make([]string, len(e))
+	for i, fe := range e {
+		lines[i] = fe.Error()
+	}
+
+	return "invalid config:\n  " + strings.Join(lines, "\n  ")
+}
+
+// validator collects problems of config fields.
+type validator struct {
+	errs ValidationError
+}
+
+func (v *validator) add(field, format string, args ...any) {
+	v.errs = append(v.errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) required(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.add(field, "is required")
+	}
+}
+
+func (v *validator) port(field string, port int) {
+	if port < 1 || port > 65535 {
+		v.add(field, "must be in range 1-65535, got %d", port)
+	}
+}
+
+func (v *validator) positive(field string, d time.Duration) {
+	if d <= 0 {
+		v.add(field, "must be positive duration, got %s", d)
+	}
+}
+
+func (v *validator) nonNegative(field string, d time.Duration) {
+	if d < 0 {
+		v.add(field, "must not be negative, got %s", d)
+	}
+}
+
+func (v *validator) oneOf(field, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+
+	v.add(field, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+}
+
+func (v *validator) tls(field string, c TLSConf) {
+	v.required(field+".cert_file", c.CertFile)
+	v.required(field+".key_file", c.KeyFile)
+
+	mode, err := server.ParseClientAuth(c.ClientAuth)
+	if err != nil {
+		v.add(field+".client_auth", "must be one of off, optional, required, got %q", c.ClientAuth)
+	} else if mode != server.ClientAuthOff && len(c.ClientCA) == 0 {
+		v.add(field+".client_ca", "is required when client_auth is %s", c.ClientAuth)
+	}
+
+	if _, err := server.ParseTLSVersion(c.MinVersion); err != nil {
+		v.add(field+".min_version", "must be one of 1.2, 1.3, got %q", c.MinVersion)
+	}
+
+	if _, err := server.ParseCipherSuites(c.CipherSuites...); err != nil {
+		v.add(field+".cipher_suites", "%v", err)
+	}
+}
+
+// Validate checks required fields, ranges and formats. It reports every
+// problem at once as ValidationError.
+func (c Config) Validate() error {
+	var v validator
+
+	v.oneOf("log.level", strings.ToLower(c.Log.Level), "debug", "info", "warn", "error")
+	v.oneOf("log.format", strings.ToLower(c.Log.Format), "json", "text")
+
+	v.required("server.host", c.Server.Host)
+	v.port("server.port", c.Server.Port)
+	v.nonNegative("server.read_timeout", c.Server.ReadTimeout)
+	v.nonNegative("server.write_timeout", c.Server.WriteTimeout)
+	v.nonNegative("server.shutdown_timeout", c.Server.ShutdownTimeout)
+	v.nonNegative("server.drain_delay", c.Server.DrainDelay)
+	if c.Server.TLS.Enabled {
+		v.tls("server.tls", c.Server.TLS)
+	}
+
+	if c.Metrics.Enabled && c.Metrics.Port != 0 {
+		v.port("metrics.port", c.Metrics.Port)
+		if c.Metrics.Port == c.Server.Port {
+			v.add("metrics.port", "must differ from server.port, use 0 to serve metrics on server port")
+		}
+	}
+
+	if c.Tracing.Enabled {
+		if _, port, err := net.SplitHostPort(c.Tracing.Endpoint); err != nil {
+			v.add("tracing.endpoint", "must be host:port, got %q", c.Tracing.Endpoint)
+		} else if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			v.add("tracing.endpoint", "has invalid port %q", port)
+		}
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		v.add("tracing.sample_ratio", "must be in range 0-1, got %g", c.Tracing.SampleRatio)
+	}
+
+	if c.GRPC.Enabled {
+		v.required("grpc.host", c.GRPC.Host)
+		v.port("grpc.port", c.GRPC.Port)
+		switch {
+		case c.GRPC.Port == c.Server.Port:
+			v.add("grpc.port", "must differ from server.port")
+		case c.Metrics.Enabled && c.Metrics.Port != 0 && c.GRPC.Port == c.Metrics.Port:
+			v.add("grpc.port", "must differ from metrics.port")
+		}
+	}
+
+	v.required("superuser.username", c.Admin.Username)
+	v.required("superuser.password", c.Admin.Password.Value())
+	if c.Admin.Email != "" {
+		if _, err := mail.ParseAddress(c.Admin.Email); err != nil {
+			v.add("superuser.email", "must be valid email address, got %q", c.Admin.Email)
+		}
+	}
+
+	v.positive("impersonation.ttl", c.Impersonation.TTL)
+
+	v.oneOf("audit.sink", c.Audit.Sink, "storage", "file")
+	if c.Audit.Sink == "file" {
+		v.required("audit.path", c.Audit.Path)
+	}
+
+	v.positive("webhooks.poll_interval", c.Webhooks.PollInterval)
+	v.positive("webhooks.timeout", c.Webhooks.Timeout)
+	v.positive("webhooks.backoff", c.Webhooks.Backoff)
+	if c.Webhooks.MaxAttempts < 1 {
+		v.add("webhooks.max_attempts", "must be at least 1, got %d", c.Webhooks.MaxAttempts)
+	}
+	if c.Webhooks.MaxBackoff < c.Webhooks.Backoff {
+		v.add("webhooks.max_backoff", "must not be less than webhooks.backoff (%s), got %s", c.Webhooks.Backoff, c.Webhooks.MaxBackoff)
+	}
+
+	if c.Events.BufferSize < 0 {
+		v.add("events.buffer_size", "must not be negative, got %d", c.Events.BufferSize)
+	}
+
+	if c.GraphQL.MaxDepth < 0 {
+		v.add("graphql.max_depth", "must not be negative, got %d", c.GraphQL.MaxDepth)
+	}
+	if c.GraphQL.MaxComplexity < 0 {
+		v.add("graphql.max_complexity", "must not be negative, got %d", c.GraphQL.MaxComplexity)
+	}
+
+	if !c.API.DeprecatedSince.IsZero() && !c.API.Sunset.IsZero() && !c.API.Sunset.After(c.API.DeprecatedSince) {
+		v.add("api.sunset", "must be after api.deprecated_since")
+	}
+
+	v.nonNegative("idempotency.ttl", c.Idempotency.TTL)
+
+	for i, id := range c.Export.IncludeHashes {
+		if _, err := uuid.Parse(id); err != nil {
+			v.add(fmt.Sprintf("export.include_hashes[%d]", i), "must be user id, got %q", id)
+		}
+	}
+
+	if len(c.CertAuth.Rules) > 0 && (!c.Server.TLS.Enabled || c.Server.TLS.ClientAuth == "off") {
+		v.add("cert_auth.rules", "require server.tls with client_auth optional or required")
+	}
+	for i, rule := range c.CertAuth.Rules {
+		field := fmt.Sprintf("cert_auth.rules[%d]", i)
+		v.oneOf(field+".field", rule.Field, "cn", "uri")
+		if _, err := regexp.Compile(rule.Match); err != nil {
+			v.add(field+".match", "must be regular expression: %v", err)
+		}
+	}
+
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	valid := Default()
+	valid.Admin.Password = NewSecret("secret")
+
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   []string // field paths of expected problems
+	}{
+		{name: "valid", modify: func(c *Config) {}},
+		{
+			name: "every problem reported",
+			modify: func(c *Config) {
+				c.Server.Host = ""
+				c.Server.Port = 0
+				c.Admin.Password = Secret{}
+				c.Admin.Email = "admin"
+				c.Log.Format = "xml"
+			},
+			want: []string{"log.format", "server.host", "server.port", "superuser.password", "superuser.email"},
+		},
+		{
+			name: "durations",
+			modify: func(c *Config) {
+				c.Server.ReadTimeout = -time.Second
+				c.Impersonation.TTL = 0
+				c.Webhooks.MaxBackoff = time.Millisecond
+			},
+			want: []string{"server.read_timeout", "impersonation.ttl", "webhooks.max_backoff"},
+		},
+		{
+			name: "listeners",
+			modify: func(c *Config) {
+				c.GRPC.Enabled = true
+				c.GRPC.Port = c.Server.Port
+				c.Metrics.Enabled = true
+				c.Metrics.Port = 70000
+				c.Tracing.Enabled = true
+				c.Tracing.Endpoint = "collector"
+			},
+			want: []string{"metrics.port", "tracing.endpoint", "grpc.port"},
+		},
+		{
+			name: "grpc on metrics port",
+			modify: func(c *Config) {
+				c.GRPC.Enabled = true
+				c.GRPC.Port = 9100
+				c.Metrics.Enabled = true
+				c.Metrics.Port = 9100
+			},
+			want: []string{"grpc.port"},
+		},
+		{
+			name: "tls",
+			modify: func(c *Config) {
+				c.Server.TLS.Enabled = true
+				c.Server.TLS.KeyFile = "tls.key"
+				c.Server.TLS.ClientAuth = "required"
+				c.Server.TLS.MinVersion = "1.1"
+				c.Server.TLS.CipherSuites = []string{"TLS_RSA_WITH_RC4_128_SHA"}
+			},
+			want: []string{"server.tls.cert_file", "server.tls.client_ca", "server.tls.min_version", "server.tls.cipher_suites"},
+		},
+		{
+			name: "certificate rules without mTLS",
+			modify: func(c *Config) {
+				c.CertAuth.Rules = []CertRuleConf{{Field: "dns", Match: "("}}
+			},
+			want: []string{"cert_auth.rules", "cert_auth.rules[0].field", "cert_auth.rules[0].match"},
+		},
+		{
+			name: "file audit without path",
+			modify: func(c *Config) {
+				c.Audit.Sink = "file"
+				c.Audit.Path = ""
+				c.Export.IncludeHashes = []string{"6f1c1f3e-3b1a-4c55-9d0e-2b3f4a5b6c7d", "migrator"}
+			},
+			want: []string{"audit.path", "export.include_hashes[1]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid
+			tt.modify(&c)
+
+			err := c.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+
+			var verr ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Validate() error = %v, want ValidationError", err)
+			}
+
+			got := make([]string, len(verr))
+			for i, fe := range verr {
+				got[i] = fe.Field
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fields = %v, want %v\n%v", got, tt.want, err)
+			}
+		})
+	}
+}
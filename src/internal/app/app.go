@@ -1,31 +1,97 @@
 package app
 
 import (
-	"fmt"
+	"context"
 	"log"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/lekht/account-master/src/config"
+	"github.com/lekht/account-master/src/internal/audit"
+	"github.com/lekht/account-master/src/internal/auth"
 	"github.com/lekht/account-master/src/internal/controllers"
+	"github.com/lekht/account-master/src/internal/events"
+	"github.com/lekht/account-master/src/internal/grpcapi"
 	"github.com/lekht/account-master/src/internal/hash"
+	"github.com/lekht/account-master/src/internal/logger"
+	"github.com/lekht/account-master/src/internal/metrics"
 	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/internal/token"
+	"github.com/lekht/account-master/src/internal/tracing"
+	"github.com/lekht/account-master/src/internal/webhook"
+	"github.com/lekht/account-master/src/pkg/grpcserver"
 	"github.com/lekht/account-master/src/pkg/server"
 	"github.com/lekht/account-master/src/pkg/storage/mock"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
-func Run(cfg *config.Config) {
-	storage := mock.New()
+// tracingShutdownTimeout limits flush of buffered spans on exit.
+const tracingShutdownTimeout = 5 * time.Second
+
+// Run starts the service with cfg and serves until interrupted. On SIGHUP
+// config is read again by reload and settings safe to change while serving
+// are applied, see config.Config.Reload.
+func Run(cfg *config.Config, reload func() (config.Config, error)) {
+	// level is changed by reload
+	var level slog.LevelVar
+	lvl, err := logger.ParseLevel(cfg.Log.Level)
+	if err != nil {
+		log.Panicf("failed to create logger: %v\n", err)
+	}
+	level.Set(lvl)
+
+	l, err := logger.NewWithLevel(os.Stdout, &level, cfg.Log.Format)
+	if err != nil {
+		log.Panicf("failed to create logger: %v\n", err)
+	}
+	// route standard log and gin output through structured logger
+	slog.SetDefault(l)
+	if !l.Enabled(context.Background(), slog.LevelDebug) {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	broker := events.New(cfg.Events.BufferSize)
+	storage := mock.New(mock.WithPublisher(broker))
+
+	// incoming traceparent is propagated even when export is disabled
+	tracing.SetPropagator()
+
+	// repository of user API, instrumented when tracing or metrics are enabled
+	var repo controllers.Repository = storage
+
+	var tp *sdktrace.TracerProvider
+	if cfg.Tracing.Enabled {
+		tp, err = tracing.New(context.Background(), cfg.Tracing.Endpoint, cfg.Tracing.Insecure, cfg.Tracing.SampleRatio)
+		if err != nil {
+			log.Panicf("failed to create tracer provider: %v\n", err)
+		}
+		tracing.Install(tp)
+		repo = tracing.Repository(repo)
+	}
+
+	var m *metrics.Metrics
+	if cfg.Metrics.Enabled {
+		m = metrics.New()
+		repo = m.Repository(repo)
+		m.Accounts(func(ctx context.Context) (int, error) {
+			users, err := storage.Users(ctx)
+			return len(users), err
+		})
+	}
 
 	// create admin
 	{
-		hash, err := hash.HashPassword(cfg.Admin.Password)
+		hash, err := hash.HashPassword(context.Background(), cfg.Admin.Password.Value())
 		if err != nil {
 			log.Panicf("failed to hash admin pwd: %v\n", err)
 		}
 
-		err = storage.CreateUser(model.Profile{
+		_, err = storage.CreateUser(context.Background(), model.Profile{
 			Email:    cfg.Admin.Email,
 			Username: cfg.Admin.Username,
 			Password: hash,
@@ -36,24 +102,261 @@ func Run(cfg *config.Config) {
 		}
 	}
 
-	router := controllers.New(storage)
+	if cfg.Impersonation.Key.Value() == "" {
+		l.Warn("app - Run - impersonation.key is not set, tokens are signed with random key: they are invalid after restart and on other replicas")
+	}
+
+	tokens, err := token.New([]byte(cfg.Impersonation.Key.Value()), cfg.Impersonation.TTL)
+	if err != nil {
+		log.Panicf("failed to create token issuer: %v\n", err)
+	}
+
+	var sink audit.Sink
+	switch cfg.Audit.Sink {
+	case "file":
+		fileSink, err := audit.NewFileSink(cfg.Audit.Path)
+		if err != nil {
+			log.Panicf("failed to create audit sink: %v\n", err)
+		}
+		sink = fileSink
+	case "", "storage":
+		sink = audit.NewStorageSink(storage)
+	default:
+		log.Panicf("unknown audit sink %q\n", cfg.Audit.Sink)
+	}
+
+	dispatcher := webhook.NewDispatcher(storage,
+		webhook.PollInterval(cfg.Webhooks.PollInterval),
+		webhook.Timeout(cfg.Webhooks.Timeout),
+		webhook.MaxAttempts(cfg.Webhooks.MaxAttempts),
+		webhook.Backoff(cfg.Webhooks.Backoff, cfg.Webhooks.MaxBackoff),
+		webhook.ShutdownTimeout(cfg.Server.ShutdownTimeout),
+		webhook.Logger(l),
+	)
+
+	routerOpts := []controllers.Option{
+		controllers.Logger(l),
+		controllers.Tokens(tokens),
+		controllers.Audit(sink),
+		controllers.Webhooks(storage),
+		controllers.Events(broker),
+		controllers.HealthCheck("storage", storage),
+		controllers.Idempotency(storage, cfg.Idempotency.TTL),
+		controllers.ExportHashes(userIDs(cfg.Export.IncludeHashes)...),
+		controllers.WriteTimeout(cfg.Server.WriteTimeout),
+		controllers.GraphQLLimits(cfg.GraphQL.MaxDepth, cfg.GraphQL.MaxComplexity),
+		controllers.Deprecate(controllers.Deprecation{
+			Since:  cfg.API.DeprecatedSince,
+			Sunset: cfg.API.Sunset,
+		}),
+	}
+
+	if len(cfg.CertAuth.Rules) > 0 {
+		rules, err := certRules(cfg.CertAuth.Rules)
+		if err != nil {
+			log.Panicf("failed to configure certificate auth: %v\n", err)
+		}
+		routerOpts = append(routerOpts, controllers.CertRules(rules...))
+	}
+
+	var authOpts []auth.Option
+	if m != nil {
+		routerOpts = append(routerOpts, controllers.Metrics(m))
+		authOpts = append(authOpts, auth.Observe(m))
+	}
+
+	router := controllers.New(repo, routerOpts...)
 
-	httpserver := server.New(router.Router(), server.Adress(cfg.Server.Host, cfg.Server.Port))
+	// nil channel blocks forever when metrics use main port
+	var adminNotify <-chan error
+	var adminsrv *server.Server
+	switch {
+	case m == nil:
+	case cfg.Metrics.Port == 0:
+		router.Router().GET("/metrics", gin.WrapH(m.Handler()))
+	default:
+		adminsrv = server.New(m.Handler(), server.Adress(cfg.Metrics.Host, cfg.Metrics.Port))
+		adminNotify = adminsrv.Notify()
+	}
+
+	opts := []server.Option{server.Adress(cfg.Server.Host, cfg.Server.Port)}
+	if cfg.Server.ReadTimeout > 0 {
+		opts = append(opts, server.ReadTimeout(cfg.Server.ReadTimeout))
+	}
+	if cfg.Server.WriteTimeout > 0 {
+		opts = append(opts, server.WriteTimeout(cfg.Server.WriteTimeout))
+	}
+	if cfg.Server.ShutdownTimeout > 0 {
+		opts = append(opts, server.ShutdownTimeout(cfg.Server.ShutdownTimeout))
+	}
+	opts = append(opts, server.DrainDelay(cfg.Server.DrainDelay), server.OnShutdown(router.Drain), server.OnShutdown(broker.Close))
+	if cfg.Server.TLS.Enabled {
+		tlsOpts, err := tlsOptions(cfg.Server.TLS)
+		if err != nil {
+			log.Panicf("failed to configure TLS: %v\n", err)
+		}
+		opts = append(opts, tlsOpts...)
+	}
+
+	httpserver := server.New(router.Router(), opts...)
+
+	// nil channel blocks forever when gRPC is disabled
+	var grpcNotify <-chan error
+	var grpcsrv *grpcserver.Server
+	if cfg.GRPC.Enabled {
+		grpcsrv = grpcserver.New(
+			grpcapi.New(repo, auth.New(repo, tokens, authOpts...), grpcapi.Audit(sink)),
+			grpcserver.Adress(cfg.GRPC.Host, cfg.GRPC.Port),
+		)
+		grpcNotify = grpcsrv.Notify()
+	}
 
 	interrupt := make(chan os.Signal, 1)
 	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
 
-	select {
-	case s := <-interrupt:
-		log.Println("app - Run - signal: " + s.String())
-		break
-	case err := <-httpserver.Notify():
-		log.Println(fmt.Errorf("app - Run - httpServer.Notify: %w", err))
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	current := *cfg
+wait:
+	for {
+		select {
+		case <-hangup:
+			current = reloadConfig(l, current, reload, &level, router)
+		case s := <-interrupt:
+			l.Info("app - Run - signal", "signal", s.String())
+			break wait
+		case err := <-httpserver.Notify():
+			l.Error("app - Run - httpServer.Notify", "error", err)
+			break wait
+		case err := <-grpcNotify:
+			l.Error("app - Run - grpcServer.Notify", "error", err)
+			break wait
+		case err := <-adminNotify:
+			l.Error("app - Run - adminServer.Notify", "error", err)
+			break wait
+		}
 	}
+	signal.Stop(hangup)
 
 	// Shutdown
-	err := httpserver.Shutdown()
+	err = httpserver.Shutdown()
+	if err != nil {
+		l.Error("app - Run - httpServer.Shutdown", "error", err)
+	}
+
+	if grpcsrv != nil {
+		if err = grpcsrv.Shutdown(); err != nil {
+			l.Error("app - Run - grpcServer.Shutdown", "error", err)
+		}
+	}
+
+	if adminsrv != nil {
+		if err = adminsrv.Shutdown(); err != nil {
+			l.Error("app - Run - adminServer.Shutdown", "error", err)
+		}
+	}
+
+	dispatcher.Shutdown()
+
+	if tp != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+
+		if err = tp.Shutdown(ctx); err != nil {
+			l.Error("app - Run - tracerProvider.Shutdown", "error", err)
+		}
+	}
+}
+
+// tlsOptions returns options of HTTPS server described by c.
+func tlsOptions(c config.TLSConf) ([]server.Option, error) {
+	mode, err := server.ParseClientAuth(c.ClientAuth)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := server.ParseTLSVersion(c.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	suites, err := server.ParseCipherSuites(c.CipherSuites...)
 	if err != nil {
-		log.Println(fmt.Errorf("app - Run - httpServer.Shutdown: %w", err))
+		return nil, err
 	}
+
+	return []server.Option{
+		server.TLS(c.CertFile, c.KeyFile),
+		server.ClientCA(mode, c.ClientCA...),
+		server.MinTLSVersion(version),
+		server.CipherSuites(suites...),
+	}, nil
+}
+
+// userIDs parses validated user ids of config.
+func userIDs(ss []string) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(ss))
+	for _, s := range ss {
+		if id, err := uuid.Parse(s); err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// certRules returns rules mapping client certificates to accounts.
+func certRules(conf []config.CertRuleConf) ([]auth.CertRule, error) {
+	rules := make([]auth.CertRule, 0, len(conf))
+	for _, c := range conf {
+		rule, err := auth.ParseCertRule(c.Field, c.Match, c.Username)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// reloadConfig reads config again and applies its reloadable settings.
+// Invalid config is ignored and changes requiring restart are rejected,
+// keeping values of cur. It returns config in effect.
+func reloadConfig(l *slog.Logger, cur config.Config, reload func() (config.Config, error), level *slog.LevelVar, router *controllers.Router) config.Config {
+	next, err := reload()
+	if err == nil {
+		err = next.Validate()
+	}
+	if err != nil {
+		l.Error("app - Run - reload, keeping current config", "error", err)
+		return cur
+	}
+
+	next, changed, rejected := cur.Reload(next)
+	if len(rejected) > 0 {
+		l.Warn("app - Run - reload, changes require restart", "fields", rejected)
+	}
+	if len(changed) == 0 {
+		l.Info("app - Run - reload, nothing to apply")
+		return next
+	}
+
+	// validated above
+	lvl, _ := logger.ParseLevel(next.Log.Level)
+	level.Set(lvl)
+
+	router.Reconfigure(controllers.Settings{
+		IdempotencyTTL:       next.Idempotency.TTL,
+		ExportHashes:         userIDs(next.Export.IncludeHashes),
+		GraphQLMaxDepth:      next.GraphQL.MaxDepth,
+		GraphQLMaxComplexity: next.GraphQL.MaxComplexity,
+		Deprecation: controllers.Deprecation{
+			Since:  next.API.DeprecatedSince,
+			Sunset: next.API.Sunset,
+		},
+	})
+
+	l.Info("app - Run - reload, config applied", "fields", changed)
+	return next
 }
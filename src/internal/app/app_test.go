@@ -0,0 +1,94 @@
+package app
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/lekht/account-master/src/config"
+	"github.com/lekht/account-master/src/internal/controllers"
+	"github.com/lekht/account-master/src/pkg/storage/mock"
+)
+
+func TestReloadConfig(t *testing.T) {
+	cur := config.Default()
+	cur.Admin.Password = config.NewSecret("secret")
+
+	tests := []struct {
+		name      string
+		modify    func(c *config.Config)
+		reloadErr error
+		want      func(c *config.Config)
+		wantLevel slog.Level
+	}{
+		{
+			name: "reloadable applied",
+			modify: func(c *config.Config) {
+				c.Log.Level = "debug"
+				c.GraphQL.MaxDepth = 4
+			},
+			want: func(c *config.Config) {
+				c.Log.Level = "debug"
+				c.GraphQL.MaxDepth = 4
+			},
+			wantLevel: slog.LevelDebug,
+		},
+		{
+			name: "invalid ignored",
+			modify: func(c *config.Config) {
+				c.Log.Level = "debug"
+				c.GraphQL.MaxDepth = -1
+			},
+			wantLevel: slog.LevelInfo,
+		},
+		{
+			name:      "unreadable ignored",
+			reloadErr: errors.New("yaml: line 1: did not find expected key"),
+			wantLevel: slog.LevelInfo,
+		},
+		{
+			name: "restart required rejected",
+			modify: func(c *config.Config) {
+				c.Log.Level = "warn"
+				c.Server.Port = 9000
+			},
+			want: func(c *config.Config) {
+				c.Log.Level = "warn"
+			},
+			wantLevel: slog.LevelWarn,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var level slog.LevelVar
+			l := slog.New(slog.NewTextHandler(io.Discard, nil))
+			router := controllers.New(mock.New())
+
+			reload := func() (config.Config, error) {
+				next := cur
+				if tt.modify != nil {
+					tt.modify(&next)
+				}
+				return next, tt.reloadErr
+			}
+
+			got := reloadConfig(l, cur, reload, &level, router)
+
+			want := cur
+			if tt.want != nil {
+				tt.want(&want)
+			}
+
+			// fields of got differing from want
+			if _, changed, rejected := want.Reload(got); len(changed)+len(rejected) != 0 {
+				t.Errorf("config differs in %v", append(changed, rejected...))
+			}
+
+			if level.Level() != tt.wantLevel {
+				t.Errorf("level = %v, want %v", level.Level(), tt.wantLevel)
+			}
+		})
+	}
+}
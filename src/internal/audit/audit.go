@@ -0,0 +1,123 @@
+package audit
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lekht/account-master/src/internal/model"
+)
+
+const (
+	ActionCreate = "user.create"
+	ActionUpdate = "user.update"
+	ActionDelete = "user.delete"
+
+	redacted = "[REDACTED]"
+)
+
+// Sink stores audit records and allows to query them.
+type Sink interface {
+	Write(model.AuditRecord) error
+	Query(Filter) ([]model.AuditRecord, error)
+}
+
+// Filter of audit records. Zero fields match everything.
+type Filter struct {
+	Actor    string
+	Action   string
+	TargetId uuid.UUID
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+}
+
+func (f Filter) Match(r model.AuditRecord) bool {
+	if f.Actor != "" && f.Actor != r.Actor && f.Actor != r.Impersonator {
+		return false
+	}
+
+	if f.Action != "" && f.Action != r.Action {
+		return false
+	}
+
+	if f.TargetId != uuid.Nil && f.TargetId != r.TargetId {
+		return false
+	}
+
+	if !f.Since.IsZero() && r.Time.Before(f.Since) {
+		return false
+	}
+
+	if !f.Until.IsZero() && !r.Time.Before(f.Until) {
+		return false
+	}
+
+	return true
+}
+
+// Apply returns records matching filter, newest first.
+func (f Filter) Apply(records []model.AuditRecord) []model.AuditRecord {
+	res := make([]model.AuditRecord, 0)
+	for _, r := range records {
+		if f.Match(r) {
+			res = append(res, r)
+		}
+	}
+
+	return f.newest(res)
+}
+
+// newest sorts matched records newest first and cuts them to limit.
+func (f Filter) newest(res []model.AuditRecord) []model.AuditRecord {
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Time.After(res[j].Time)
+	})
+
+	if f.Limit > 0 && len(res) > f.Limit {
+		res = res[:f.Limit]
+	}
+
+	return res
+}
+
+// Diff returns changed fields between two profile states. Password values
+// are never included, only the fact of change.
+func Diff(before, after model.Profile) []model.Change {
+	changes := make([]model.Change, 0)
+
+	if before.Email != after.Email {
+		changes = append(changes, model.Change{Field: "email", Before: before.Email, After: after.Email})
+	}
+
+	if before.Username != after.Username {
+		changes = append(changes, model.Change{Field: "username", Before: before.Username, After: after.Username})
+	}
+
+	if before.Password != after.Password {
+		changes = append(changes, model.Change{Field: "password", Before: redact(before.Password), After: redact(after.Password)})
+	}
+
+	if before.Admin != after.Admin {
+		changes = append(changes, model.Change{Field: "admin", Before: before.Admin, After: after.Admin})
+	}
+
+	return changes
+}
+
+func redact(secret string) any {
+	if secret == "" {
+		return nil
+	}
+
+	return redacted
+}
+
+// Discard sink drops all records.
+var Discard Sink = discard{}
+
+type discard struct{}
+
+func (discard) Write(model.AuditRecord) error { return nil }
+
+func (discard) Query(Filter) ([]model.AuditRecord, error) { return []model.AuditRecord{}, nil }
@@ -0,0 +1,116 @@
+package audit
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lekht/account-master/src/internal/model"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		before model.Profile
+		after  model.Profile
+		want   []model.Change
+	}{
+		{
+			name:   "no changes",
+			before: model.Profile{Username: "test"},
+			after:  model.Profile{Username: "test"},
+			want:   []model.Change{},
+		},
+		{
+			name:   "create",
+			before: model.Profile{},
+			after:  model.Profile{Username: "test", Password: "hash", Admin: true},
+			want: []model.Change{
+				{Field: "username", Before: "", After: "test"},
+				{Field: "password", After: redacted},
+				{Field: "admin", Before: false, After: true},
+			},
+		},
+		{
+			name:   "password change is redacted",
+			before: model.Profile{Password: "old"},
+			after:  model.Profile{Password: "new"},
+			want:   []model.Change{{Field: "password", Before: redacted, After: redacted}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(tt.before, tt.after); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileSink_Query(t *testing.T) {
+	sink, err := NewFileSink(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatalf("NewFileSink() error = %v", err)
+	}
+
+	target := uuid.New()
+	now := time.Now().UTC().Truncate(time.Second)
+	records := []model.AuditRecord{
+		{Id: uuid.New(), Time: now.Add(-2 * time.Minute), Actor: "admin", Action: ActionCreate, TargetId: target},
+		{Id: uuid.New(), Time: now.Add(-time.Minute), Actor: "admin", Action: ActionUpdate, TargetId: target},
+		{Id: uuid.New(), Time: now, Actor: "root", Action: ActionDelete, TargetId: uuid.New()},
+	}
+	for _, r := range records {
+		if err = sink.Write(r); err != nil {
+			t.Fatalf("FileSink.Write() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []uuid.UUID
+	}{
+		{
+			name:   "all newest first",
+			filter: Filter{},
+			want:   []uuid.UUID{records[2].Id, records[1].Id, records[0].Id},
+		},
+		{
+			name:   "by actor and target",
+			filter: Filter{Actor: "admin", TargetId: target},
+			want:   []uuid.UUID{records[1].Id, records[0].Id},
+		},
+		{
+			name:   "by action",
+			filter: Filter{Action: ActionDelete},
+			want:   []uuid.UUID{records[2].Id},
+		},
+		{
+			name:   "time range with limit",
+			filter: Filter{Since: now.Add(-2 * time.Minute), Until: now, Limit: 1},
+			want:   []uuid.UUID{records[1].Id},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sink.Query(tt.filter)
+			if err != nil {
+				t.Fatalf("FileSink.Query() error = %v", err)
+			}
+
+			ids := make([]uuid.UUID, 0, len(got))
+			for _, r := range got {
+				ids = append(ids, r.Id)
+			}
+
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("FileSink.Query() = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
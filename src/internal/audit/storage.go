@@ -0,0 +1,31 @@
+package audit
+
+import "github.com/lekht/account-master/src/internal/model"
+
+// Store is storage backend able to keep audit records.
+type Store interface {
+	CreateAuditRecord(model.AuditRecord) error
+	AuditRecords() ([]model.AuditRecord, error)
+}
+
+// StorageSink keeps audit records in storage backend.
+type StorageSink struct {
+	store Store
+}
+
+func NewStorageSink(store Store) *StorageSink {
+	return &StorageSink{store: store}
+}
+
+func (s *StorageSink) Write(r model.AuditRecord) error {
+	return s.store.CreateAuditRecord(r)
+}
+
+func (s *StorageSink) Query(f Filter) ([]model.AuditRecord, error) {
+	records, err := s.store.AuditRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	return f.Apply(records), nil
+}
@@ -0,0 +1,248 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lekht/account-master/src/internal/hash"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/internal/token"
+	"github.com/lekht/account-master/src/pkg/storage"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/lekht/account-master/src/internal/auth")
+
+var (
+	ErrNoCredentials      = errors.New("authentication required")
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrInvalidToken       = errors.New("invalid token")
+	ErrInvalidCertificate = errors.New("no account for client certificate")
+)
+
+// Users is part of repository needed for authentication.
+type Users interface {
+	UserByID(context.Context, uuid.UUID) (model.Profile, error)
+	UserByName(context.Context, string) (model.Profile, error)
+}
+
+// Identity of authenticated caller. For impersonation tokens user fields
+// describe subject and Impersonator fields describe admin acting as subject.
+// Certificate is identity taken from client certificate, e.g. cn:billing,
+// when caller authenticated with one.
+type Identity struct {
+	UserID         uuid.UUID
+	Username       string
+	Admin          bool
+	ImpersonatorID uuid.UUID
+	Impersonator   string
+	Certificate    string
+}
+
+func (i Identity) Impersonated() bool {
+	return i.ImpersonatorID != uuid.Nil
+}
+
+// Observer is notified about authentication results and password checks,
+// e.g. to export metrics.
+type Observer interface {
+	AuthSucceeded(method string)
+	AuthFailed(method, reason string)
+	ObservePasswordCheck(time.Duration)
+}
+
+// Authentication methods and failure reasons reported to Observer.
+const (
+	MethodBasic       = "basic"
+	MethodBearer      = "bearer"
+	MethodCertificate = "certificate"
+	MethodNone        = "none"
+
+	ReasonNoCredentials      = "no_credentials"
+	ReasonInvalidCredentials = "invalid_credentials"
+	ReasonInvalidToken       = "invalid_token"
+	ReasonInvalidCertificate = "invalid_certificate"
+	ReasonError              = "error"
+)
+
+// Authenticator checks Basic credentials, impersonation tokens and client
+// certificates.
+type Authenticator struct {
+	users     Users
+	tokens    *token.Issuer
+	observer  Observer
+	certRules []CertRule
+}
+
+type Option func(*Authenticator)
+
+// Observe sets observer of authentication results.
+func Observe(o Observer) Option {
+	return func(a *Authenticator) {
+		a.observer = o
+	}
+}
+
+func New(users Users, tokens *token.Issuer, opts ...Option) *Authenticator {
+	a := &Authenticator{users: users, tokens: tokens}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+// Authorization authenticates value of Authorization header,
+// either Basic credentials or Bearer impersonation token.
+func (a *Authenticator) Authorization(ctx context.Context, header string) (Identity, error) {
+	ctx, span := tracer.Start(ctx, "auth.Authorization")
+	defer span.End()
+
+	method, id, err := a.authorization(ctx, header)
+	return a.finish(span, method, id, err)
+}
+
+// finish records result of authentication with method in span and observer.
+func (a *Authenticator) finish(span trace.Span, method string, id Identity, err error) (Identity, error) {
+	span.SetAttributes(attribute.String("auth.method", method))
+
+	if err == nil {
+		span.SetAttributes(attribute.String("enduser.id", id.UserID.String()))
+		if a.observer != nil {
+			a.observer.AuthSucceeded(method)
+		}
+		return id, err
+	}
+
+	reason := failureReason(err)
+	span.SetAttributes(attribute.String("auth.failure_reason", reason))
+	if reason == ReasonError {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	if a.observer != nil {
+		a.observer.AuthFailed(method, reason)
+	}
+
+	return id, err
+}
+
+func failureReason(err error) string {
+	switch {
+	case errors.Is(err, ErrNoCredentials):
+		return ReasonNoCredentials
+	case errors.Is(err, ErrInvalidCredentials):
+		return ReasonInvalidCredentials
+	case errors.Is(err, ErrInvalidToken):
+		return ReasonInvalidToken
+	case errors.Is(err, ErrInvalidCertificate):
+		return ReasonInvalidCertificate
+	default:
+		return ReasonError
+	}
+}
+
+func (a *Authenticator) authorization(ctx context.Context, header string) (string, Identity, error) {
+	scheme, value, _ := strings.Cut(header, " ")
+
+	switch {
+	case strings.EqualFold(scheme, "Bearer") && value != "":
+		id, err := a.Token(ctx, value)
+		return MethodBearer, id, err
+	case strings.EqualFold(scheme, "Basic") && value != "":
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return MethodBasic, Identity{}, ErrNoCredentials
+		}
+
+		username, password, ok := strings.Cut(string(decoded), ":")
+		if !ok {
+			return MethodBasic, Identity{}, ErrNoCredentials
+		}
+
+		id, err := a.Basic(ctx, username, password)
+		return MethodBasic, id, err
+	default:
+		return MethodNone, Identity{}, ErrNoCredentials
+	}
+}
+
+func (a *Authenticator) Basic(ctx context.Context, username, password string) (Identity, error) {
+	user, err := a.users.UserByName(ctx, username)
+	if errors.Is(err, storage.ErrNoUsername) {
+		return Identity{}, ErrInvalidCredentials
+	} else if err != nil {
+		return Identity{}, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	start := time.Now()
+	isSame, _ := hash.CheckPassword(ctx, password, user.Password)
+	if a.observer != nil {
+		a.observer.ObservePasswordCheck(time.Since(start))
+	}
+
+	if !isSame {
+		return Identity{}, ErrInvalidCredentials
+	}
+
+	return Identity{
+		UserID:   user.Id,
+		Username: user.Username,
+		Admin:    user.Admin,
+	}, nil
+}
+
+// Token authenticates impersonation token. Both actor and subject are
+// checked again, so token becomes invalid once actor loses admin rights
+// or subject is promoted to admin.
+func (a *Authenticator) Token(ctx context.Context, tok string) (Identity, error) {
+	claims, err := a.tokens.Parse(tok)
+	if err != nil {
+		return Identity{}, ErrInvalidToken
+	}
+
+	actor, err := a.users.UserByID(ctx, claims.Actor)
+	if err != nil && !errors.Is(err, storage.ErrNoUserID) {
+		return Identity{}, fmt.Errorf("failed to get actor: %w", err)
+	} else if err != nil || !actor.Admin {
+		return Identity{}, ErrInvalidToken
+	}
+
+	subject, err := a.users.UserByID(ctx, claims.Subject)
+	if err != nil && !errors.Is(err, storage.ErrNoUserID) {
+		return Identity{}, fmt.Errorf("failed to get subject: %w", err)
+	} else if err != nil || subject.Admin {
+		return Identity{}, ErrInvalidToken
+	}
+
+	return Identity{
+		UserID:         subject.Id,
+		Username:       subject.Username,
+		Admin:          subject.Admin,
+		ImpersonatorID: actor.Id,
+		Impersonator:   actor.Username,
+	}, nil
+}
+
+type identityKey struct{}
+
+// NewContext returns context carrying identity.
+func NewContext(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns identity stored by NewContext.
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/lekht/account-master/src/pkg/storage"
+)
+
+// Fields of client certificate matched by CertRule.
+const (
+	CertFieldCN  = "cn"
+	CertFieldURI = "uri"
+)
+
+// CertRule maps verified client certificate to account. Value of Field
+// (subject CN or any URI SAN) fully matching Pattern gives username by
+// expanding Username template with submatches, e.g. pattern
+// spiffe://example\.org/svc/([a-z-]+) with username $1.
+type CertRule struct {
+	Field    string
+	Pattern  *regexp.Regexp
+	Username string
+}
+
+// ParseCertRule returns rule of field (cn or uri), regular expression
+// pattern and username template. Pattern must match whole value, so
+// "admin" does not match "not-admin-really". Empty template means whole
+// value.
+func ParseCertRule(field, pattern, username string) (CertRule, error) {
+	if field != CertFieldCN && field != CertFieldURI {
+		return CertRule{}, fmt.Errorf("unknown certificate field %q", field)
+	}
+
+	re, err := regexp.Compile(`^(?:` + pattern + `)$`)
+	if err != nil {
+		return CertRule{}, fmt.Errorf("invalid certificate pattern: %w", err)
+	}
+
+	if username == "" {
+		username = "$0"
+	}
+
+	return CertRule{Field: field, Pattern: re, Username: username}, nil
+}
+
+// CertRules enables authentication with client certificates mapped to
+// accounts by rules. The first matching rule wins.
+func CertRules(rules ...CertRule) Option {
+	return func(a *Authenticator) {
+		a.certRules = rules
+	}
+}
+
+// match returns username and identity of cert given by rule.
+func (r CertRule) match(cert *x509.Certificate) (username, identity string, ok bool) {
+	values := []string{cert.Subject.CommonName}
+	if r.Field == CertFieldURI {
+		values = values[:0]
+		for _, u := range cert.URIs {
+			values = append(values, u.String())
+		}
+	}
+
+	for _, v := range values {
+		m := r.Pattern.FindStringSubmatchIndex(v)
+		if m == nil {
+			continue
+		}
+
+		return string(r.Pattern.ExpandString(nil, r.Username, v, m)), r.Field + ":" + v, true
+	}
+
+	return "", "", false
+}
+
+// Certificate authenticates verified client certificate by CertRules.
+// Without rules every certificate is treated as missing credentials.
+func (a *Authenticator) Certificate(ctx context.Context, cert *x509.Certificate) (Identity, error) {
+	ctx, span := tracer.Start(ctx, "auth.Certificate")
+	defer span.End()
+
+	id, err := a.certificate(ctx, cert)
+	return a.finish(span, MethodCertificate, id, err)
+}
+
+func (a *Authenticator) certificate(ctx context.Context, cert *x509.Certificate) (Identity, error) {
+	if cert == nil || len(a.certRules) == 0 {
+		return Identity{}, ErrNoCredentials
+	}
+
+	for _, rule := range a.certRules {
+		username, identity, ok := rule.match(cert)
+		if !ok {
+			continue
+		}
+
+		user, err := a.users.UserByName(ctx, username)
+		if errors.Is(err, storage.ErrNoUsername) {
+			return Identity{}, ErrInvalidCertificate
+		} else if err != nil {
+			return Identity{}, fmt.Errorf("failed to get user: %w", err)
+		}
+
+		return Identity{
+			UserID:      user.Id,
+			Username:    user.Username,
+			Admin:       user.Admin,
+			Certificate: identity,
+		}, nil
+	}
+
+	return Identity{}, ErrInvalidCertificate
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/url"
+	"testing"
+)
+
+func TestCertRule_Match(t *testing.T) {
+	cert := func(cn string, uris ...string) *x509.Certificate {
+		c := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+		for _, s := range uris {
+			u, _ := url.Parse(s)
+			c.URIs = append(c.URIs, u)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name         string
+		field        string
+		pattern      string
+		username     string
+		cert         *x509.Certificate
+		wantUsername string
+		wantIdentity string
+		wantOK       bool
+	}{
+		{
+			name: "whole cn", field: CertFieldCN, pattern: "admin",
+			cert: cert("admin"), wantUsername: "admin", wantIdentity: "cn:admin", wantOK: true,
+		},
+		{
+			name: "cn substring", field: CertFieldCN, pattern: "admin",
+			cert: cert("not-admin-really"),
+		},
+		{
+			name: "uri with template", field: CertFieldURI, pattern: `spiffe://example\.org/svc/([a-z-]+)`, username: "$1",
+			cert:         cert("ignored", "https://example.org", "spiffe://example.org/svc/billing"),
+			wantUsername: "billing", wantIdentity: "uri:spiffe://example.org/svc/billing", wantOK: true,
+		},
+		{
+			name: "uri prefix", field: CertFieldURI, pattern: `spiffe://example\.org/svc/([a-z-]+)`, username: "$1",
+			cert: cert("billing", "spiffe://example.org/svc/billing/extra"),
+		},
+		{
+			name: "explicit anchors", field: CertFieldCN, pattern: `^svc-([a-z]+)$`, username: "$1",
+			cert: cert("svc-billing"), wantUsername: "billing", wantIdentity: "cn:svc-billing", wantOK: true,
+		},
+		{
+			name: "alternation is anchored as a whole", field: CertFieldCN, pattern: "admin|root",
+			cert: cert("admin-evil"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseCertRule(tt.field, tt.pattern, tt.username)
+			if err != nil {
+				t.Fatalf("ParseCertRule() error = %v", err)
+			}
+
+			username, identity, ok := rule.match(tt.cert)
+			if username != tt.wantUsername || identity != tt.wantIdentity || ok != tt.wantOK {
+				t.Errorf("match() = %q, %q, %v, want %q, %q, %v", username, identity, ok, tt.wantUsername, tt.wantIdentity, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseCertRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		field   string
+		pattern string
+		wantErr bool
+	}{
+		{name: "cn", field: CertFieldCN, pattern: "svc"},
+		{name: "unknown field", field: "dns", pattern: "svc", wantErr: true},
+		{name: "invalid pattern", field: CertFieldURI, pattern: "(", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCertRule(tt.field, tt.pattern, ""); (err != nil) != tt.wantErr {
+				t.Errorf("ParseCertRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,7 +1,14 @@
 package controllers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/lekht/account-master/src/internal/model"
@@ -26,6 +33,35 @@ type AccountResponse struct {
 	Admin    bool      `json:"admin"`
 }
 
+// ETag is strong validator of response representation.
+func (a AccountResponse) ETag() string {
+	b, _ := json.Marshal(a)
+	sum := sha256.Sum256(b)
+
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+type ImpersonationResponse struct {
+	Token     string    `json:"token"`
+	Actor     uuid.UUID `json:"actor"`
+	Subject   uuid.UUID `json:"subject"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type WebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+}
+
+type WebhookResponse struct {
+	Id        uuid.UUID `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 func requestToProfile(req *AccountRequest) (*model.Profile, error) {
 	if req == nil {
 		return nil, ErrNillReq
@@ -52,6 +88,32 @@ func requestToProfile(req *AccountRequest) (*model.Profile, error) {
 	return &p, nil
 }
 
+// validateProfile checks profile before it replaces stored one.
+// Email may be cleared, but must be valid address when set.
+func validateProfile(p model.Profile, setPassword bool) error {
+	var errs []string
+
+	if strings.TrimSpace(p.Username) == "" {
+		errs = append(errs, "username must not be empty")
+	}
+
+	if p.Email != "" {
+		if addr, err := mail.ParseAddress(p.Email); err != nil || addr.Address != p.Email {
+			errs = append(errs, "email is not valid address")
+		}
+	}
+
+	if setPassword && p.Password == "" {
+		errs = append(errs, "password must not be empty")
+	}
+
+	if len(errs) > 0 {
+		return newProblem(http.StatusUnprocessableEntity, CodeValidationFailed, strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
 func profileToResponse(p *model.Profile) (*AccountResponse, error) {
 	if p == nil {
 		return nil, ErrNillProfile
@@ -65,3 +127,17 @@ func profileToResponse(p *model.Profile) (*AccountResponse, error) {
 
 	return &a, nil
 }
+
+func webhookToResponse(w model.Webhook) WebhookResponse {
+	events := w.Events
+	if events == nil {
+		events = []string{}
+	}
+
+	return WebhookResponse{
+		Id:        w.Id,
+		URL:       w.URL,
+		Events:    events,
+		CreatedAt: w.CreatedAt,
+	}
+}
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lekht/account-master/src/internal/audit"
+	"github.com/lekht/account-master/src/internal/model"
+)
+
+// recordAudit writes audit record of account mutation. Failure to write record
+// does not fail the request, it is only logged.
+func (r *Router) recordAudit(c *gin.Context, action string, target uuid.UUID, before, after model.Profile) {
+	rec := model.AuditRecord{
+		Id:        uuid.New(),
+		Time:      time.Now().UTC(),
+		Actor:     c.GetString("username"),
+		Action:    action,
+		TargetId:  target,
+		Changes:   audit.Diff(before, after),
+		SourceIP:  c.ClientIP(),
+		RequestId: requestID(c),
+	}
+
+	if id, ok := c.Get("userID"); ok {
+		rec.ActorId = id.(uuid.UUID)
+	}
+
+	if id, ok := c.Get("impersonatorID"); ok {
+		rec.ImpersonatorId = id.(uuid.UUID)
+		rec.Impersonator = c.GetString("impersonator")
+	}
+
+	rec.Certificate = c.GetString("certificate")
+
+	if err := r.audit.Write(rec); err != nil {
+		requestLogger(c).Error("failed to write audit record", "audit_id", rec.Id, "error", err)
+	}
+}
+
+// getAudit
+//
+//	@Summary		Get Audit Records
+//	@Description	Get account mutation records, newest first
+//	@Security		BasicAuth
+//	@Produce		json
+//	@Param			actor	query	string	false	"Actor or impersonator username"
+//	@Param			action	query	string	false	"Action"	Enums(user.create, user.update, user.delete)
+//	@Param			target	query	string	false	"Target user ID"
+//	@Param			since	query	string	false	"RFC 3339 time, inclusive"
+//	@Param			until	query	string	false	"RFC 3339 time, exclusive"
+//	@Param			limit	query	int		false	"Max number of records"
+//	@Success		200
+//	@Failure		400	{object}	Problem
+//	@Failure		403	{object}	Problem
+//	@Router			/audit [get]
+func (r *Router) getAudit(c *gin.Context) {
+	f := audit.Filter{
+		Actor:  c.Query("actor"),
+		Action: c.Query("action"),
+	}
+
+	var err error
+
+	if v := c.Query("target"); v != "" {
+		if f.TargetId, err = uuid.Parse(v); err != nil {
+			abortWithError(c, newProblem(http.StatusBadRequest, CodeInvalidParameter, "invalid target id"))
+			return
+		}
+	}
+
+	if v := c.Query("since"); v != "" {
+		if f.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			abortWithError(c, newProblem(http.StatusBadRequest, CodeInvalidParameter, "invalid since"))
+			return
+		}
+	}
+
+	if v := c.Query("until"); v != "" {
+		if f.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			abortWithError(c, newProblem(http.StatusBadRequest, CodeInvalidParameter, "invalid until"))
+			return
+		}
+	}
+
+	if v := c.Query("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 0 {
+			abortWithError(c, newProblem(http.StatusBadRequest, CodeInvalidParameter, "invalid limit"))
+			return
+		}
+	}
+
+	records, err := r.audit.Query(f)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": records})
+}
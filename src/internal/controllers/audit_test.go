@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lekht/account-master/src/internal/audit"
+	"github.com/lekht/account-master/src/internal/hash"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage/mock"
+)
+
+func TestRouter_Audit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	m := mock.New()
+	for _, p := range []model.Profile{
+		{Email: "admin@example.org", Username: "admin", Admin: true},
+		{Email: "user@example.org", Username: "user"},
+	} {
+		p.Password, _ = hash.HashPassword(context.Background(), p.Username)
+		if _, err := m.CreateUser(context.Background(), p); err != nil {
+			t.Fatalf("CreateUser() error = %v", err)
+		}
+	}
+
+	r := New(m, Audit(audit.NewStorageSink(m)))
+
+	do := func(user, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth(user, user)
+		w := httptest.NewRecorder()
+
+		r.Router().ServeHTTP(w, req)
+		return w
+	}
+
+	// every mutation leaves one record
+	w := do("admin", http.MethodPost, "/v1/user", `{"email":"carol@example.org","username":"carol","password":"secret"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create status = %d: %s", w.Code, w.Body)
+	}
+	var carol model.Profile
+	if err := json.Unmarshal(w.Body.Bytes(), &carol); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if w = do("admin", http.MethodPut, "/v1/user/"+carol.Id.String(), `{"email":"carol@example.com","username":"carol","admin":false}`); w.Code != http.StatusOK {
+		t.Fatalf("update status = %d: %s", w.Code, w.Body)
+	}
+
+	if w = do("admin", http.MethodDelete, "/v1/user/"+carol.Id.String(), ""); w.Code != http.StatusOK {
+		t.Fatalf("delete status = %d: %s", w.Code, w.Body)
+	}
+
+	tests := []struct {
+		name    string
+		user    string
+		query   url.Values
+		status  int
+		actions []string
+	}{
+		{
+			name: "all newest first", user: "admin",
+			status:  http.StatusOK,
+			actions: []string{audit.ActionDelete, audit.ActionUpdate, audit.ActionCreate},
+		},
+		{
+			name: "by action", user: "admin",
+			query:   url.Values{"action": {audit.ActionUpdate}},
+			status:  http.StatusOK,
+			actions: []string{audit.ActionUpdate},
+		},
+		{
+			name: "by target and actor", user: "admin",
+			query:   url.Values{"target": {carol.Id.String()}, "actor": {"admin"}},
+			status:  http.StatusOK,
+			actions: []string{audit.ActionDelete, audit.ActionUpdate, audit.ActionCreate},
+		},
+		{
+			name: "by other actor", user: "admin",
+			query:  url.Values{"actor": {"user"}},
+			status: http.StatusOK,
+		},
+		{
+			name: "with limit", user: "admin",
+			query:   url.Values{"limit": {"1"}},
+			status:  http.StatusOK,
+			actions: []string{audit.ActionDelete},
+		},
+		{
+			name: "until past", user: "admin",
+			query:  url.Values{"until": {time.Now().Add(-time.Hour).Format(time.RFC3339)}},
+			status: http.StatusOK,
+		},
+		{
+			name: "invalid target", user: "admin",
+			query:  url.Values{"target": {"42"}},
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "invalid since", user: "admin",
+			query:  url.Values{"since": {"yesterday"}},
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "negative limit", user: "admin",
+			query:  url.Values{"limit": {"-1"}},
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "non-admin", user: "user",
+			status: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(tt.user, http.MethodGet, "/v1/audit?"+tt.query.Encode(), "")
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var resp struct{ Data []model.AuditRecord }
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			got := make([]string, 0, len(resp.Data))
+			for _, rec := range resp.Data {
+				got = append(got, rec.Action)
+				if rec.Actor != "admin" || rec.TargetId != carol.Id {
+					t.Errorf("record = %+v", rec)
+				}
+			}
+
+			if strings.Join(got, ",") != strings.Join(tt.actions, ",") {
+				t.Errorf("actions = %v, want %v", got, tt.actions)
+			}
+		})
+	}
+
+	t.Run("update changes", func(t *testing.T) {
+		w := do("admin", http.MethodGet, "/v1/audit?action="+audit.ActionUpdate, "")
+
+		var resp struct{ Data []model.AuditRecord }
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Data) != 1 {
+			t.Fatalf("Unmarshal() = %s, error = %v", w.Body, err)
+		}
+
+		want := model.Change{Field: "email", Before: "carol@example.org", After: "carol@example.com"}
+		if changes := resp.Data[0].Changes; len(changes) != 1 || changes[0] != want {
+			t.Errorf("changes = %+v, want %+v", changes, want)
+		}
+	})
+}
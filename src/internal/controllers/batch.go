@@ -0,0 +1,291 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lekht/account-master/src/internal/audit"
+	"github.com/lekht/account-master/src/internal/hash"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage"
+)
+
+const maxBatchOperations = 1000
+
+// Batch operation types.
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+// BatchOperation changes single user. Update changes only fields set in user.
+type BatchOperation struct {
+	Op   string          `json:"op"`
+	Id   uuid.UUID       `json:"id,omitzero"`
+	User *AccountRequest `json:"user,omitempty"`
+}
+
+// BatchRequest runs operations in order. Atomic request is applied
+// all-or-nothing, otherwise every operation is applied on its own.
+type BatchRequest struct {
+	Atomic     bool             `json:"atomic"`
+	Operations []BatchOperation `json:"operations"`
+}
+
+// BatchResult of single operation. Status is HTTP status the operation
+// would get as separate request.
+type BatchResult struct {
+	Index  int              `json:"index"`
+	Op     string           `json:"op"`
+	Status int              `json:"status"`
+	Id     uuid.UUID        `json:"id,omitzero"`
+	User   *AccountResponse `json:"user,omitempty"`
+	Error  *Problem         `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Atomic    bool          `json:"atomic"`
+	Committed bool          `json:"committed"`
+	Results   []BatchResult `json:"results"`
+}
+
+// batchChange is applied operation, recorded to audit after commit.
+type batchChange struct {
+	action        string
+	id            uuid.UUID
+	before, after model.Profile
+}
+
+// errBatchFailed stops unit of work after failed operation.
+var errBatchFailed = errors.New("batch operation failed")
+
+// batchUsers()
+//
+//	@Summary		Batch Users
+//	@Description	Create, update and delete users in one request. With atomic=true operations
+//	@Description	are applied all-or-nothing in repository transaction, results of operations
+//	@Description	not applied because of rollback have status 424
+//	@Security		BasicAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			batch			body	BatchRequest	true	"operations"
+//	@Param			Idempotency-Key	header	string			false	"Key to safely retry request"
+//	@Success		200	{object}	BatchResponse
+//	@Failure		400	{object}	Problem
+//	@Failure		403	{object}	Problem
+//	@Failure		501	{object}	Problem
+//	@Router			/user/batch [post]
+func (r *Router) batchUsers(c *gin.Context) {
+	var req BatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidJSON)
+		return
+	}
+
+	if len(req.Operations) == 0 || len(req.Operations) > maxBatchOperations {
+		abortWithError(c, newProblem(http.StatusBadRequest, CodeInvalidParameter,
+			fmt.Sprintf("batch must have from 1 to %d operations", maxBatchOperations)))
+		return
+	}
+
+	txRepo, transactional := r.repo.(TxRepository)
+	if req.Atomic && !transactional {
+		abortWithError(c, newProblem(http.StatusNotImplemented, CodeTxNotSupported, "repository does not support transactions"))
+		return
+	}
+
+	resp := BatchResponse{Atomic: req.Atomic, Results: make([]BatchResult, len(req.Operations))}
+
+	// passwords are hashed before unit of work, which may hold storage lock
+	for i := range req.Operations {
+		op := &req.Operations[i]
+		resp.Results[i] = BatchResult{Index: i, Op: op.Op, Id: op.Id}
+
+		if err := r.prepareBatchOperation(c, op); err != nil {
+			setBatchError(&resp.Results[i], err)
+		}
+	}
+
+	changes := make([]batchChange, 0, len(req.Operations))
+
+	run := func(tx storage.Tx) error {
+		for i, op := range req.Operations {
+			res := &resp.Results[i]
+			if res.Error == nil {
+				change, err := applyBatchOperation(c.Request.Context(), tx, op, res)
+				if err == nil {
+					changes = append(changes, change)
+					continue
+				}
+				setBatchError(res, err)
+			}
+
+			if req.Atomic {
+				return errBatchFailed
+			}
+		}
+
+		return nil
+	}
+
+	var err error
+	if req.Atomic {
+		err = txRepo.WithinTx(c.Request.Context(), run)
+	} else {
+		err = run(r.repo)
+	}
+
+	switch {
+	case errors.Is(err, errBatchFailed):
+		for i := range resp.Results {
+			if resp.Results[i].Error == nil {
+				resp.Results[i].Status = http.StatusFailedDependency
+				resp.Results[i].User = nil
+				resp.Results[i].Error = newProblem(http.StatusFailedDependency, CodeBatchRolledBack, "batch was rolled back")
+			}
+		}
+		changes = nil
+	case err != nil:
+		abortWithError(c, err)
+		return
+	default:
+		resp.Committed = true
+	}
+
+	for _, ch := range changes {
+		r.recordAudit(c, ch.action, ch.id, ch.before, ch.after)
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// prepareBatchOperation validates shape of op and hashes its password.
+func (r *Router) prepareBatchOperation(c *gin.Context, op *BatchOperation) error {
+	switch op.Op {
+	case BatchCreate:
+		if op.User == nil {
+			return newProblem(http.StatusBadRequest, CodeInvalidParameter, "create requires user")
+		}
+	case BatchUpdate:
+		if op.Id == uuid.Nil || op.User == nil {
+			return newProblem(http.StatusBadRequest, CodeInvalidParameter, "update requires id and user")
+		}
+	case BatchDelete:
+		if op.Id == uuid.Nil {
+			return errInvalidUserID
+		}
+		return nil
+	default:
+		return newProblem(http.StatusBadRequest, CodeInvalidParameter, "unknown op "+op.Op)
+	}
+
+	if op.User.Password == nil {
+		return nil
+	}
+
+	if isImpersonated(c) {
+		return errPasswordImpersonated
+	}
+
+	if *op.User.Password == "" {
+		return newProblem(http.StatusUnprocessableEntity, CodeValidationFailed, "password must not be empty")
+	}
+
+	pwdHash, err := hash.HashPassword(c.Request.Context(), *op.User.Password)
+	if err != nil {
+		return err
+	}
+	op.User.Password = &pwdHash
+
+	return nil
+}
+
+// applyBatchOperation runs prepared op and fills res on success.
+func applyBatchOperation(ctx context.Context, tx storage.Tx, op BatchOperation, res *BatchResult) (batchChange, error) {
+	switch op.Op {
+	case BatchCreate:
+		u, _ := requestToProfile(op.User)
+		if err := validateProfile(*u, true); err != nil {
+			return batchChange{}, err
+		}
+
+		created, err := tx.CreateUser(ctx, *u)
+		if err != nil {
+			return batchChange{}, err
+		}
+
+		res.Status, res.Id = http.StatusCreated, created.Id
+		res.User, _ = profileToResponse(&created)
+
+		return batchChange{action: audit.ActionCreate, id: created.Id, after: created}, nil
+	case BatchUpdate:
+		before, err := tx.UserByID(ctx, op.Id)
+		if err != nil {
+			return batchChange{}, err
+		}
+
+		u := mergeRequest(before, op.User)
+		if err = validateProfile(u, op.User.Password != nil); err != nil {
+			return batchChange{}, err
+		}
+
+		if err = tx.UpdateUser(ctx, op.Id, u); err != nil {
+			return batchChange{}, err
+		}
+
+		after, err := tx.UserByID(ctx, op.Id)
+		if err != nil {
+			return batchChange{}, err
+		}
+
+		res.Status = http.StatusOK
+		res.User, _ = profileToResponse(&after)
+
+		return batchChange{action: audit.ActionUpdate, id: op.Id, before: before, after: after}, nil
+	default:
+		before, err := tx.UserByID(ctx, op.Id)
+		if err != nil {
+			return batchChange{}, err
+		}
+
+		if err = tx.DeleteUser(ctx, op.Id); err != nil {
+			return batchChange{}, err
+		}
+
+		res.Status = http.StatusOK
+
+		return batchChange{action: audit.ActionDelete, id: op.Id, before: before}, nil
+	}
+}
+
+// mergeRequest sets fields of req over p.
+func mergeRequest(p model.Profile, req *AccountRequest) model.Profile {
+	if req.Email != nil {
+		p.Email = *req.Email
+	}
+
+	if req.Username != nil {
+		p.Username = *req.Username
+	}
+
+	if req.Password != nil {
+		p.Password = *req.Password
+	}
+
+	if req.Admin != nil {
+		p.Admin = *req.Admin
+	}
+
+	return p
+}
+
+func setBatchError(res *BatchResult, err error) {
+	p := *problemFromError(err)
+	res.Status = p.Status
+	res.Error = &p
+}
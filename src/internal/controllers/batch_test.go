@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lekht/account-master/src/internal/hash"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage/mock"
+)
+
+func TestRouter_BatchUsers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name      string
+		body      func(alice, bob string) string
+		committed bool
+		statuses  []int
+		wantAdmin map[string]bool // username -> admin, absent users must not exist
+	}{
+		{
+			name: "atomic commit",
+			body: func(alice, bob string) string {
+				return `{"atomic":true,"operations":[` +
+					`{"op":"update","id":"` + alice + `","user":{"admin":false}},` +
+					`{"op":"delete","id":"` + bob + `"},` +
+					`{"op":"create","user":{"username":"carol","password":"p"}}]}`
+			},
+			committed: true,
+			statuses:  []int{http.StatusOK, http.StatusOK, http.StatusCreated},
+			wantAdmin: map[string]bool{"admin": true, "alice": false, "carol": false},
+		},
+		{
+			name: "atomic rollback",
+			body: func(alice, bob string) string {
+				return `{"atomic":true,"operations":[` +
+					`{"op":"update","id":"` + alice + `","user":{"admin":false}},` +
+					`{"op":"create","user":{"username":"bob","password":"p"}},` +
+					`{"op":"delete","id":"` + bob + `"}]}`
+			},
+			statuses:  []int{http.StatusFailedDependency, http.StatusConflict, http.StatusFailedDependency},
+			wantAdmin: map[string]bool{"admin": true, "alice": true, "bob": true},
+		},
+		{
+			name: "best effort",
+			body: func(alice, bob string) string {
+				return `{"operations":[` +
+					`{"op":"update","id":"` + alice + `","user":{"admin":false}},` +
+					`{"op":"create","user":{"username":"bob","password":"p"}},` +
+					`{"op":"rename","id":"` + bob + `"},` +
+					`{"op":"update","id":"` + bob + `","user":{"email":"bad"}}]}`
+			},
+			committed: true,
+			statuses:  []int{http.StatusOK, http.StatusConflict, http.StatusBadRequest, http.StatusUnprocessableEntity},
+			wantAdmin: map[string]bool{"admin": true, "alice": false, "bob": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mock.New()
+			pwd, _ := hash.HashPassword(context.Background(), "admin")
+			_, _ = m.CreateUser(context.Background(), model.Profile{Username: "admin", Password: pwd, Admin: true})
+			alice, _ := m.CreateUser(context.Background(), model.Profile{Username: "alice", Password: "hash", Admin: true})
+			bob, _ := m.CreateUser(context.Background(), model.Profile{Username: "bob", Password: "hash", Admin: true})
+
+			body := tt.body(alice.Id.String(), bob.Id.String())
+			req := httptest.NewRequest(http.MethodPost, "/v1/user/batch", strings.NewReader(body))
+			req.SetBasicAuth("admin", "admin")
+			w := httptest.NewRecorder()
+
+			New(m).Router().ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+
+			var resp BatchResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			if resp.Committed != tt.committed {
+				t.Errorf("committed = %v, want %v", resp.Committed, tt.committed)
+			}
+
+			for i, res := range resp.Results {
+				if res.Status != tt.statuses[i] {
+					t.Errorf("result %d status = %d, want %d (%+v)", i, res.Status, tt.statuses[i], res.Error)
+				}
+			}
+
+			users, _ := m.Users(context.Background())
+			got := make(map[string]bool, len(users))
+			for _, u := range users {
+				got[u.Username] = u.Admin
+			}
+
+			if len(got) != len(tt.wantAdmin) {
+				t.Errorf("users = %v, want %v", got, tt.wantAdmin)
+			}
+
+			for name, admin := range tt.wantAdmin {
+				if a, ok := got[name]; !ok || a != admin {
+					t.Errorf("user %q admin = %v (exists %v), want %v", name, a, ok, admin)
+				}
+			}
+		})
+	}
+}
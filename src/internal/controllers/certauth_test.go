@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lekht/account-master/src/internal/audit"
+	"github.com/lekht/account-master/src/internal/auth"
+	"github.com/lekht/account-master/src/internal/hash"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage/mock"
+)
+
+func TestRouter_CertificateAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	m := mock.New()
+	pwd, _ := hash.HashPassword(context.Background(), "admin")
+	for _, p := range []model.Profile{
+		{Email: "admin@example.org", Username: "admin", Password: pwd, Admin: true},
+		{Email: "deployer@example.org", Username: "deployer", Password: pwd, Admin: true},
+	} {
+		if _, err := m.CreateUser(context.Background(), p); err != nil {
+			t.Fatalf("CreateUser() error = %v", err)
+		}
+	}
+
+	uriRule, err := auth.ParseCertRule(auth.CertFieldURI, `^spiffe://example\.org/svc/([a-z-]+)$`, "$1")
+	if err != nil {
+		t.Fatalf("ParseCertRule() error = %v", err)
+	}
+	cnRule, err := auth.ParseCertRule(auth.CertFieldCN, `^[a-z]+$`, "")
+	if err != nil {
+		t.Fatalf("ParseCertRule() error = %v", err)
+	}
+
+	sink := audit.NewStorageSink(m)
+	r := New(m, Audit(sink), CertRules(uriRule, cnRule))
+
+	cert := func(cn, uri string) *x509.Certificate {
+		c := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+		if uri != "" {
+			u, _ := url.Parse(uri)
+			c.URIs = []*url.URL{u}
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		cert     *x509.Certificate
+		basic    bool
+		status   int
+		code     string
+		identity string
+	}{
+		{name: "uri", cert: cert("ignored", "spiffe://example.org/svc/deployer"), status: http.StatusCreated, identity: "uri:spiffe://example.org/svc/deployer"},
+		{name: "cn", cert: cert("deployer", ""), status: http.StatusCreated, identity: "cn:deployer"},
+		{name: "no account", cert: cert("ghost", ""), status: http.StatusUnauthorized, code: CodeInvalidCertificate},
+		{name: "no rule matches", cert: cert("Deployer 1", "spiffe://other.org/svc/deployer"), status: http.StatusUnauthorized, code: CodeInvalidCertificate},
+		{name: "authorization header first", cert: cert("ghost", ""), basic: true, status: http.StatusCreated},
+		{name: "no certificate", status: http.StatusUnauthorized, code: CodeAuthRequired},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := fmt.Sprintf(`{"email":"svc%d@example.org","username":"svc%d","password":"p"}`, i, i)
+			req := httptest.NewRequest(http.MethodPost, "/v1/user", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.cert != nil {
+				req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{tt.cert}}}
+			}
+			if tt.basic {
+				req.SetBasicAuth("admin", "admin")
+			}
+			w := httptest.NewRecorder()
+
+			r.Router().ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d, body %s", w.Code, tt.status, w.Body)
+			}
+
+			if tt.code != "" {
+				var p Problem
+				if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+					t.Fatalf("Unmarshal() error = %v", err)
+				}
+				if p.Code != tt.code {
+					t.Errorf("code = %q, want %q", p.Code, tt.code)
+				}
+				return
+			}
+
+			var created model.Profile
+			if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			records, err := sink.Query(audit.Filter{TargetId: created.Id})
+			if err != nil || len(records) != 1 {
+				t.Fatalf("Query() = %v, %v", records, err)
+			}
+
+			if records[0].Certificate != tt.identity {
+				t.Errorf("audit certificate = %q, want %q", records[0].Certificate, tt.identity)
+			}
+		})
+	}
+}
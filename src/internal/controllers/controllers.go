@@ -1,51 +1,137 @@
 package controllers
 
 import (
-	"errors"
+	"context"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+	"github.com/lekht/account-master/src/internal/audit"
+	"github.com/lekht/account-master/src/internal/auth"
+	"github.com/lekht/account-master/src/internal/events"
 	"github.com/lekht/account-master/src/internal/hash"
+	"github.com/lekht/account-master/src/internal/metrics"
 	"github.com/lekht/account-master/src/internal/model"
-	"github.com/lekht/account-master/src/pkg/storage/mock"
+	"github.com/lekht/account-master/src/internal/token"
+	"github.com/lekht/account-master/src/internal/webhook"
+	"github.com/lekht/account-master/src/pkg/storage"
 	swaggerfiles "github.com/swaggo/files"     // swagger embed files
 	ginSwagger "github.com/swaggo/gin-swagger" // gin-swagger middleware
 )
 
+// Repository of users. Every method takes request context, so canceled
+// requests stop storage work.
 type Repository interface {
-	Users() ([]model.Profile, error)
-	UserByID(uuid.UUID) (model.Profile, error)
-	CreateUser(model.Profile) error
-	UpdateUser(uuid.UUID, model.Profile) error
-	DeleteUser(uuid.UUID) error
-	UserByName(string) (model.Profile, error)
+	Users(context.Context) ([]model.Profile, error)
+	// UsersPage returns up to limit users with username greater than
+	// after, sorted by username.
+	UsersPage(ctx context.Context, after string, limit int) ([]model.Profile, error)
+	UserByID(context.Context, uuid.UUID) (model.Profile, error)
+	CreateUser(context.Context, model.Profile) (model.Profile, error)
+	UpdateUser(context.Context, uuid.UUID, model.Profile) error
+	DeleteUser(context.Context, uuid.UUID) error
+	UserByName(context.Context, string) (model.Profile, error)
+}
+
+// TxRepository is Repository able to run unit of work, see storage.Tx.
+type TxRepository interface {
+	Repository
+	WithinTx(ctx context.Context, fn func(tx storage.Tx) error) error
 }
 
 type Router struct {
-	repo Repository
+	repo     Repository
+	tokens   *token.Issuer
+	auth     *auth.Authenticator
+	audit    audit.Sink
+	webhooks webhook.Store
+	events   *events.Broker
+	logger   *slog.Logger
+	metrics  *metrics.Metrics
+
+	// client certificate rules of auth
+	certRules []auth.CertRule
+
+	idempotency  IdempotencyStore
+	writeTimeout time.Duration
+
+	healthChecks map[string]Pinger
+	draining     atomic.Bool
+
+	schema graphql.Schema
+
+	// mu guards settings changed by Reconfigure while serving
+	mu             sync.RWMutex
+	idempotencyTTL time.Duration
+	// ids of users allowed to export password hashes
+	exportHashes     map[uuid.UUID]bool
+	deprecation      Deprecation
+	gqlMaxDepth      int
+	gqlMaxComplexity int
 
 	router *gin.Engine
 }
 
-func New(repo Repository) *Router {
+func New(repo Repository, opts ...Option) *Router {
 	r := Router{
-		repo:   repo,
-		router: gin.New(),
+		repo:             repo,
+		router:           gin.New(),
+		gqlMaxDepth:      defaultMaxDepth,
+		gqlMaxComplexity: defaultMaxComplexity,
+		idempotencyTTL:   defaultIdempotency,
+	}
+
+	for _, opt := range opts {
+		opt(&r)
+	}
+
+	if r.tokens == nil {
+		tokens, err := token.New(nil, 0)
+		if err != nil {
+			panic(fmt.Sprintf("controllers - New - token.New: %v", err))
+		}
+		r.tokens = tokens
+	}
+
+	authOpts := []auth.Option{auth.CertRules(r.certRules...)}
+	if r.metrics != nil {
+		authOpts = append(authOpts, auth.Observe(r.metrics))
+	}
+	r.auth = auth.New(repo, r.tokens, authOpts...)
+
+	schema, err := r.newSchema()
+	if err != nil {
+		panic(fmt.Sprintf("controllers - New - newSchema: %v", err))
+	}
+	r.schema = schema
+
+	if r.audit == nil {
+		r.audit = audit.Discard
 	}
 
-	r.router.Use(gin.Logger())
-	r.router.Use(gin.Recovery())
+	if r.logger == nil {
+		r.logger = slog.Default()
+	}
 
-	authenticated := r.router.Group("/user", r.basicAuthMiddleware())
-	{
-		authenticated.GET("", r.getUsers)
-		authenticated.GET("/:id", r.getUserById)
-		authenticated.POST("", isAdminMiddleware(), r.createUser)
-		authenticated.PUT("/:id", isAdminMiddleware(), r.updateUserById)
-		authenticated.DELETE("/:id", isAdminMiddleware(), r.deleteUserById)
+	r.router.Use(tracingMiddleware())
+	r.router.Use(r.loggingMiddleware())
+	if r.metrics != nil {
+		r.router.Use(r.metricsMiddleware())
 	}
+	r.router.Use(recoveryMiddleware())
+
+	r.router.GET("/healthz", r.healthz)
+	r.router.GET("/readyz", r.readyz)
+
+	r.mountVersions(v1)
 
 	r.router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
 
@@ -64,44 +150,53 @@ func (r *Router) Router() *gin.Engine {
 //	@Accept			json
 //	@Produce		json
 //	@Param			user	body	AccountRequest	true	"Email, Username, Password, Admin"
-//	@Success		201
-//	@Failure		400
-//	@Failure		409
+//	@Param			Idempotency-Key	header	string	false	"Key to safely retry request"
+//	@Success		201	{object}	AccountResponse
+//	@Header			201	{string}	Location	"URL of created user"
+//	@Header			201	{string}	ETag		"Version of created user"
+//	@Failure		400	{object}	Problem
+//	@Failure		409	{object}	Problem
 //	@Header			all	{string}	string	"header"
 //	@Router			/user [post]
 func (r *Router) createUser(c *gin.Context) {
 	var req AccountRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "wrong json"})
+		abortWithError(c, errInvalidJSON)
 		return
 	}
 
 	usr, err := requestToProfile(&req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "wrong json"})
+		abortWithError(c, errInvalidJSON)
 		return
 	}
 
-	pwdHash, err := hash.HashPassword(usr.Password)
+	pwdHash, err := hash.HashPassword(c.Request.Context(), usr.Password)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "something go wrong"})
+		abortWithError(c, err)
 		return
 	}
 
 	usr.Password = pwdHash
 
-	err = r.repo.CreateUser(*usr)
+	created, err := r.repo.CreateUser(c.Request.Context(), *usr)
 	if err != nil {
-		if errors.Is(err, mock.ErrUserExists) {
-			c.JSON(http.StatusConflict, gin.H{"error": "user already exists"})
-			return
-		}
-		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request"})
+		abortWithError(c, err)
 		return
 	}
 
-	c.Status(http.StatusCreated)
+	r.recordAudit(c, audit.ActionCreate, created.Id, model.Profile{}, created)
+
+	resp, err := profileToResponse(&created)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.Header("Location", strings.TrimSuffix(c.FullPath(), "/")+"/"+created.Id.String())
+	c.Header("ETag", resp.ETag())
+	c.JSON(http.StatusCreated, resp)
 }
 
 // getUsers
@@ -110,21 +205,22 @@ func (r *Router) createUser(c *gin.Context) {
 //	@Description	Get full users list
 //	@Header			all	{string}	string	"header"
 //	@Security		BasicAuth
+//	@Security		BearerAuth
 //	@Accept			json
 //	@Produce		json
 //	@Return			json
 //	@Success		200
-//	@Failure		404
+//	@Failure		404	{object}	Problem
 //	@Router			/user [get]
 func (r *Router) getUsers(c *gin.Context) {
-	users, err := r.repo.Users()
+	users, err := r.repo.Users(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		abortWithError(c, err)
 		return
 	}
 
 	if users == nil {
-		c.JSON(http.StatusNotFound, gin.H{"status": "users not found"})
+		abortWithError(c, newProblem(http.StatusNotFound, CodeUsersNotFound, "users not found"))
 		return
 	}
 
@@ -132,7 +228,7 @@ func (r *Router) getUsers(c *gin.Context) {
 	for _, u := range users {
 		resp, err := profileToResponse(&u)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			abortWithError(c, err)
 			return
 		}
 
@@ -148,80 +244,134 @@ func (r *Router) getUsers(c *gin.Context) {
 //	@Description	Get specific user by ID
 //	@Header			all	{string}	string	"header"
 //	@Security		BasicAuth
+//	@Security		BearerAuth
 //	@Accept			json
 //	@Produce		json
 //	@Param			id	path	string	true	"User ID"
 //	@Return			json
 //	@Success		200
-//	@Failure		400
-//	@Failure		404
+//	@Failure		400	{object}	Problem
+//	@Failure		404	{object}	Problem
 //	@Router			/user/{id} [get]
 func (r *Router) getUserById(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := uuid.Parse(idParam)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		abortWithError(c, errInvalidUserID)
 		return
 	}
 
-	u, err := r.repo.UserByID(id)
-	if errors.Is(err, mock.ErrNoUserID) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+	u, err := r.repo.UserByID(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
 		return
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server errror"})
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"id":       u.Id,
-		"username": u.Username,
-		"email":    u.Email,
-		"admin":    u.Admin,
-	})
+	resp, err := profileToResponse(&u)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.Header("ETag", resp.ETag())
+	c.JSON(http.StatusOK, resp)
 }
 
 // updateUserById()
 //
-//	@Summary		Update User
-//	@Description	Update user by ID
+//	@Summary		Replace User
+//	@Description	Replace user by ID. Email, username and admin are required, omitted password is kept
 //	@Security		BasicAuth
 //	@Accept			json
 //	@Produce		json
 //	@Param			id		path	string			true	"User ID"
 //	@Param			user	body	AccountRequest	true	"request body"
+//	@Param			Idempotency-Key	header	string	false	"Key to safely retry request"
 //	@Success		200
-//	@Failure		400
-//	@Failure		404
+//	@Failure		400	{object}	Problem
+//	@Failure		404	{object}	Problem
+//	@Failure		409	{object}	Problem
+//	@Failure		422	{object}	Problem
 //	@Header			all	{string}	string	"header"
 //	@Router			/user/{id} [put]
 func (r *Router) updateUserById(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := uuid.Parse(idParam)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		abortWithError(c, errInvalidUserID)
 		return
 	}
 
 	var req AccountRequest
 	if err = c.ShouldBindBodyWith(&req, binding.JSON); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request"})
+		abortWithError(c, errInvalidJSON)
 		return
 	}
 
-	u, err := requestToProfile(&req)
+	if req.Password != nil && isImpersonated(c) {
+		abortWithError(c, errPasswordImpersonated)
+		return
+	}
 
-	err = r.repo.UpdateUser(id, *u)
-	if errors.Is(err, mock.ErrNoUserID) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+	if req.Email == nil || req.Username == nil || req.Admin == nil {
+		abortWithError(c, newProblem(http.StatusUnprocessableEntity, CodeValidationFailed, "email, username and admin are required"))
 		return
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+
+	before, err := r.repo.UserByID(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	u, err := requestToProfile(&req)
+	if err != nil {
+		abortWithError(c, errInvalidJSON)
+		return
+	}
+
+	if _, err = r.replaceUser(c, before, *u, req.Password != nil); err != nil {
+		abortWithError(c, err)
 		return
 	}
 
 	c.Status(http.StatusOK)
 }
 
+// replaceUser validates u, hashes its password if it was set or keeps
+// current one otherwise, stores it in place of before and records audit.
+func (r *Router) replaceUser(c *gin.Context, before, u model.Profile, setPassword bool) (model.Profile, error) {
+	u.Id = before.Id
+
+	if err := validateProfile(u, setPassword); err != nil {
+		return model.Profile{}, err
+	}
+
+	if setPassword {
+		pwdHash, err := hash.HashPassword(c.Request.Context(), u.Password)
+		if err != nil {
+			return model.Profile{}, err
+		}
+		u.Password = pwdHash
+	} else {
+		u.Password = before.Password
+	}
+
+	if err := r.repo.UpdateUser(c.Request.Context(), before.Id, u); err != nil {
+		return model.Profile{}, err
+	}
+
+	after, err := r.repo.UserByID(c.Request.Context(), before.Id)
+	if err != nil {
+		requestLogger(c).Error("failed to find updated user for audit", "user_id", before.Id, "error", err)
+		return u, nil
+	}
+
+	r.recordAudit(c, audit.ActionUpdate, before.Id, before, after)
+
+	return after, nil
+}
+
 // deleteUserById()
 //
 //	@Summary		Delete User
@@ -230,27 +380,88 @@ func (r *Router) updateUserById(c *gin.Context) {
 //	@Accept			json
 //	@Produce		json
 //	@Param			id	path	string	true	"User ID"
+//	@Param			Idempotency-Key	header	string	false	"Key to safely retry request"
 //	@Success		200
-//	@Failure		400
-//	@Failure		404
+//	@Failure		400	{object}	Problem
+//	@Failure		404	{object}	Problem
 //	@Header			all	{string}	string	"header"
 //	@Router			/user/{id} [delete]
 func (r *Router) deleteUserById(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := uuid.Parse(idParam)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		abortWithError(c, errInvalidUserID)
 		return
 	}
 
-	err = r.repo.DeleteUser(id)
-	if errors.Is(err, mock.ErrNoUserID) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+	before, err := r.repo.UserByID(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
 		return
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+
+	err = r.repo.DeleteUser(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
 		return
 	}
 
+	r.recordAudit(c, audit.ActionDelete, id, before, model.Profile{})
+
 	c.Status(http.StatusOK)
 }
+
+// impersonateUser()
+//
+//	@Summary		Impersonate User
+//	@Description	Issue short-lived token to act as user by ID. Admins can not be impersonated
+//	@Security		BasicAuth
+//	@Produce		json
+//	@Param			id	path	string	true	"User ID"
+//	@Success		200	{object}	ImpersonationResponse
+//	@Failure		400	{object}	Problem
+//	@Failure		403	{object}	Problem
+//	@Failure		404	{object}	Problem
+//	@Router			/user/{id}/impersonate [post]
+func (r *Router) impersonateUser(c *gin.Context) {
+	if isImpersonated(c) {
+		abortWithError(c, newProblem(http.StatusForbidden, CodeImpersonationDenied, "impersonation token can not be used to impersonate"))
+		return
+	}
+
+	idParam := c.Param("id")
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		abortWithError(c, errInvalidUserID)
+		return
+	}
+
+	subject, err := r.repo.UserByID(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	if subject.Admin {
+		abortWithError(c, newProblem(http.StatusForbidden, CodeImpersonationDenied, "admin can not be impersonated"))
+		return
+	}
+
+	actor := c.MustGet("userID").(uuid.UUID)
+
+	tok, exp, err := r.tokens.Issue(actor, subject.Id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	requestLogger(c).Info("impersonation issued",
+		"actor_id", actor, "subject", subject.Username, "subject_id", subject.Id, "expires", exp.Format(time.RFC3339))
+
+	c.JSON(http.StatusOK, ImpersonationResponse{
+		Token:     tok,
+		Actor:     actor,
+		Subject:   subject.Id,
+		ExpiresAt: exp,
+	})
+}
@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lekht/account-master/src/internal/hash"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage/mock"
+)
+
+func TestRouter_CreateUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	m := mock.New()
+	pwd, _ := hash.HashPassword(context.Background(), "admin")
+	if _, err := m.CreateUser(context.Background(), model.Profile{Username: "admin", Password: pwd, Admin: true}); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	r := New(m)
+
+	tests := []struct {
+		name     string
+		path     string
+		username string
+	}{
+		{name: "versioned", path: "/v1/user", username: "first"},
+		{name: "legacy alias", path: "/user", username: "second"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"email":"a@b.c","username":"` + tt.username + `","password":"p","admin":false}`
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(body))
+			req.SetBasicAuth("admin", "admin")
+			w := httptest.NewRecorder()
+
+			r.Router().ServeHTTP(w, req)
+
+			if w.Code != http.StatusCreated {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+			}
+
+			var resp AccountResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			stored, err := m.UserByID(context.Background(), resp.Id)
+			if err != nil || stored.Username != tt.username {
+				t.Fatalf("stored user = %+v, %v", stored, err)
+			}
+
+			if got, want := w.Header().Get("Location"), tt.path+"/"+resp.Id.String(); got != want {
+				t.Errorf("Location = %q, want %q", got, want)
+			}
+
+			// GET of created user reports the same version
+			get := httptest.NewRequest(http.MethodGet, w.Header().Get("Location"), nil)
+			get.SetBasicAuth("admin", "admin")
+			gw := httptest.NewRecorder()
+			r.Router().ServeHTTP(gw, get)
+
+			if etag := w.Header().Get("ETag"); etag == "" || etag != gw.Header().Get("ETag") {
+				t.Errorf("ETag = %q, GET ETag = %q", etag, gw.Header().Get("ETag"))
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lekht/account-master/src/internal/events"
+)
+
+const defaultHeartbeat = 15 * time.Second
+
+type EventResponse struct {
+	Id   string          `json:"id"`
+	Type string          `json:"type"`
+	Time time.Time       `json:"time"`
+	User AccountResponse `json:"user"`
+}
+
+// streamEvents
+//
+//	@Summary		User Events
+//	@Description	Server-Sent Events stream of user changes. Send Last-Event-ID header to resume.
+//	@Description	Last-Event-ID of lost events (evicted from buffer or sent before restart) gets reset event and all buffered events
+//	@Security		BasicAuth
+//	@Produce		text/event-stream
+//	@Param			Last-Event-ID	header	string	false	"Id of last received event"
+//	@Success		200
+//	@Failure		400	{object}	Problem
+//	@Failure		403	{object}	Problem
+//	@Router			/user/events [get]
+func (r *Router) streamEvents(c *gin.Context) {
+	var after uint64
+	if v := c.GetHeader("Last-Event-ID"); v != "" {
+		var err error
+		if after, err = strconv.ParseUint(v, 10, 64); err != nil {
+			abortWithError(c, newProblem(http.StatusBadRequest, CodeInvalidParameter, "invalid Last-Event-ID"))
+			return
+		}
+	}
+
+	backlog, ch, cancel, reset := r.events.Subscribe(after)
+	defer cancel()
+
+	heartbeat := defaultHeartbeat
+	if r.writeTimeout > 0 {
+		heartbeat = min(heartbeat, r.writeTimeout/2)
+	}
+
+	// every write gets its own deadline, so server WriteTimeout limits
+	// single write instead of whole stream
+	writeTimeout := r.writeTimeout
+	if writeTimeout <= 0 {
+		writeTimeout = 2 * heartbeat
+	}
+
+	rc := http.NewResponseController(c.Writer)
+	write := func(fn func(w io.Writer) error) bool {
+		_ = rc.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if err := fn(c.Writer); err != nil {
+			return false
+		}
+
+		return rc.Flush() == nil
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	if !write(func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "retry: %d\n\n", time.Second.Milliseconds())
+		return err
+	}) {
+		return
+	}
+
+	// id 0 makes client resume from start of new sequence if it reconnects
+	// before any event of backlog
+	if reset && !write(func(w io.Writer) error {
+		_, err := io.WriteString(w, "id: 0\nevent: reset\ndata: {}\n\n")
+		return err
+	}) {
+		return
+	}
+
+	for _, e := range backlog {
+		if !write(eventWriter(e)) {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case e, ok := <-ch:
+			if !ok {
+				// subscriber was too slow or broker closed on shutdown,
+				// client resumes with Last-Event-ID
+				return
+			}
+
+			if !write(eventWriter(e)) {
+				return
+			}
+		case <-ticker.C:
+			if !write(func(w io.Writer) error {
+				_, err := io.WriteString(w, ": heartbeat\n\n")
+				return err
+			}) {
+				return
+			}
+		}
+	}
+}
+
+func eventWriter(e events.Entry) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		user, err := profileToResponse(&e.Event.User)
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(EventResponse{
+			Id:   e.Event.Id.String(),
+			Type: e.Event.Type,
+			Time: e.Event.Time,
+			User: *user,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Seq, e.Event.Type, data)
+		return err
+	}
+}
@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lekht/account-master/src/internal/events"
+	"github.com/lekht/account-master/src/internal/hash"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage/mock"
+)
+
+func TestRouter_Events(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// events 1-4, buffer keeps 3 and 4
+	broker := events.New(2)
+	m := mock.New(mock.WithPublisher(broker))
+	for _, p := range []model.Profile{
+		{Email: "admin@example.org", Username: "admin", Admin: true},
+		{Email: "user@example.org", Username: "user"},
+		{Email: "carol@example.org", Username: "carol"},
+		{Email: "dave@example.org", Username: "dave"},
+	} {
+		p.Password, _ = hash.HashPassword(context.Background(), p.Username)
+		if _, err := m.CreateUser(context.Background(), p); err != nil {
+			t.Fatalf("CreateUser() error = %v", err)
+		}
+	}
+
+	// heartbeat every 50ms
+	r := New(m, Events(broker), WriteTimeout(100*time.Millisecond))
+	srv := httptest.NewServer(r.Router())
+	defer srv.Close()
+
+	created := "event: " + model.EventUserCreated
+
+	tests := []struct {
+		name        string
+		user        string
+		lastEventID string
+		status      int
+		want        []string
+		heartbeat   bool
+	}{
+		{
+			name: "all buffered", user: "admin",
+			status: http.StatusOK,
+			want:   []string{"id: 3", created, "id: 4", created},
+		},
+		{
+			name: "resume", user: "admin", lastEventID: "3",
+			status: http.StatusOK,
+			want:   []string{"id: 4", created},
+		},
+		{
+			name: "resume at oldest buffered", user: "admin", lastEventID: "2",
+			status: http.StatusOK,
+			want:   []string{"id: 3", created, "id: 4", created},
+		},
+		{
+			name: "missed events evicted", user: "admin", lastEventID: "1",
+			status: http.StatusOK,
+			want:   []string{"id: 0", "event: reset", "id: 3", created, "id: 4", created},
+		},
+		{
+			name: "id of previous run", user: "admin", lastEventID: "42",
+			status: http.StatusOK,
+			want:   []string{"id: 0", "event: reset", "id: 3", created, "id: 4", created},
+		},
+		{
+			name: "heartbeat", user: "admin", lastEventID: "4",
+			status:    http.StatusOK,
+			heartbeat: true,
+		},
+		{
+			name: "invalid id", user: "admin", lastEventID: "last",
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "non-admin", user: "user",
+			status: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/v1/user/events", nil)
+			req.SetBasicAuth(tt.user, tt.user)
+			if tt.lastEventID != "" {
+				req.Header.Set("Last-Event-ID", tt.lastEventID)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var got []string
+			heartbeat := false
+			scanner := bufio.NewScanner(resp.Body)
+			for (len(got) < len(tt.want) || tt.heartbeat && !heartbeat) && scanner.Scan() {
+				switch line := scanner.Text(); {
+				case line == ": heartbeat":
+					heartbeat = true
+				case strings.HasPrefix(line, "id: "), strings.HasPrefix(line, "event: "):
+					got = append(got, line)
+				}
+			}
+
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("events = %q, want %q", got, tt.want)
+			}
+
+			if tt.heartbeat && !heartbeat {
+				t.Error("no heartbeat")
+			}
+		})
+	}
+}
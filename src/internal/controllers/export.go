@@ -0,0 +1,262 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lekht/account-master/src/internal/model"
+)
+
+const (
+	exportPageSize = 500
+	hashField      = "password_hash"
+)
+
+// exportFields are fields exported by default, in column order.
+var exportFields = []string{"id", "email", "username", "admin"}
+
+func exportValue(p model.Profile, field string) any {
+	switch field {
+	case "id":
+		return p.Id
+	case "email":
+		return p.Email
+	case "username":
+		return p.Username
+	case "admin":
+		return p.Admin
+	case hashField:
+		return p.Password
+	default:
+		return nil
+	}
+}
+
+// exportEncoder writes users in single format. Begin and End wrap records,
+// Flush is called after every page.
+type exportEncoder interface {
+	Begin() error
+	Record(p model.Profile) error
+	Flush() error
+	End() error
+}
+
+// exportUsers()
+//
+//	@Summary		Export Users
+//	@Description	Stream all users sorted by username. Password hashes are exported only
+//	@Description	with include_hashes=true by admins granted include_hashes permission
+//	@Security		BasicAuth
+//	@Produce		text/csv
+//	@Produce		application/x-ndjson
+//	@Produce		json
+//	@Param			format			query	string	false	"csv, ndjson or json (default)"
+//	@Param			fields			query	string	false	"Comma separated fields: id,email,username,admin"
+//	@Param			include_hashes	query	bool	false	"Add password_hash field"
+//	@Success		200
+//	@Failure		400	{object}	Problem
+//	@Failure		403	{object}	Problem
+//	@Router			/user/export [get]
+func (r *Router) exportUsers(c *gin.Context) {
+	fields := exportFields
+	if v := c.Query("fields"); v != "" {
+		fields = nil
+		for _, f := range strings.Split(v, ",") {
+			f = strings.TrimSpace(f)
+			if !slices.Contains(exportFields, f) {
+				abortWithError(c, newProblem(http.StatusBadRequest, CodeInvalidParameter, "unknown field "+f))
+				return
+			}
+
+			if !slices.Contains(fields, f) {
+				fields = append(fields, f)
+			}
+		}
+	}
+
+	includeHashes, err := strconv.ParseBool(c.DefaultQuery("include_hashes", "false"))
+	if err != nil {
+		abortWithError(c, newProblem(http.StatusBadRequest, CodeInvalidParameter, "invalid include_hashes"))
+		return
+	}
+
+	if includeHashes {
+		if isImpersonated(c) || !r.canExportHashes(c.MustGet("userID").(uuid.UUID)) {
+			abortWithError(c, newProblem(http.StatusForbidden, CodePermissionDenied, "include_hashes permission is required"))
+			return
+		}
+
+		fields = append(slices.Clip(fields), hashField)
+	}
+
+	format := c.DefaultQuery("format", "json")
+
+	var enc exportEncoder
+	switch format {
+	case "csv":
+		enc = &csvExport{w: csv.NewWriter(c.Writer), fields: fields}
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+	case "ndjson":
+		enc = &jsonExport{w: c.Writer, fields: fields, lines: true}
+		c.Header("Content-Type", ndjsonType)
+	case "json":
+		enc = &jsonExport{w: c.Writer, fields: fields}
+		c.Header("Content-Type", "application/json; charset=utf-8")
+	default:
+		abortWithError(c, newProblem(http.StatusBadRequest, CodeInvalidParameter, "format must be csv, ndjson or json"))
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="users.`+format+`"`)
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	// every page gets its own write deadline, so server WriteTimeout
+	// does not cut long export
+	rc := http.NewResponseController(c.Writer)
+	extend := func() {
+		if r.writeTimeout > 0 {
+			_ = rc.SetWriteDeadline(time.Now().Add(r.writeTimeout))
+		}
+	}
+
+	extend()
+	if err := enc.Begin(); err != nil {
+		return
+	}
+
+	var after string
+	for {
+		users, err := r.repo.UsersPage(c.Request.Context(), after, exportPageSize)
+		if err != nil {
+			// status is already sent, response is left truncated
+			requestLogger(c).Warn("export interrupted", "after", after, "error", err)
+			return
+		}
+
+		extend()
+		for _, u := range users {
+			if err = enc.Record(u); err != nil {
+				return
+			}
+		}
+
+		if enc.Flush() != nil || rc.Flush() != nil {
+			return
+		}
+
+		if len(users) < exportPageSize {
+			break
+		}
+		after = users[len(users)-1].Username
+	}
+
+	if err := enc.End(); err == nil {
+		_ = rc.Flush()
+	}
+}
+
+type csvExport struct {
+	w      *csv.Writer
+	fields []string
+}
+
+func (e *csvExport) Begin() error {
+	return e.w.Write(e.fields)
+}
+
+func (e *csvExport) Record(p model.Profile) error {
+	record := make([]string, len(e.fields))
+	for i, f := range e.fields {
+		switch v := exportValue(p, f).(type) {
+		case bool:
+			record[i] = strconv.FormatBool(v)
+		case interface{ String() string }:
+			record[i] = v.String()
+		case string:
+			record[i] = v
+		}
+	}
+
+	return e.w.Write(record)
+}
+
+func (e *csvExport) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvExport) End() error {
+	return e.Flush()
+}
+
+// jsonExport writes JSON array or, with lines, one object per line.
+type jsonExport struct {
+	w      io.Writer
+	fields []string
+	lines  bool
+	n      int
+}
+
+func (e *jsonExport) Begin() error {
+	if e.lines {
+		return nil
+	}
+
+	_, err := io.WriteString(e.w, "[")
+	return err
+}
+
+// Record writes object with members in order of fields.
+func (e *jsonExport) Record(p model.Profile) error {
+	var b []byte
+	if !e.lines && e.n > 0 {
+		b = append(b, ',')
+	}
+	e.n++
+
+	b = append(b, '{')
+	for i, f := range e.fields {
+		if i > 0 {
+			b = append(b, ',')
+		}
+
+		v, err := json.Marshal(exportValue(p, f))
+		if err != nil {
+			return err
+		}
+
+		b = strconv.AppendQuote(b, f)
+		b = append(b, ':')
+		b = append(b, v...)
+	}
+	b = append(b, '}')
+
+	if e.lines {
+		b = append(b, '\n')
+	}
+
+	_, err := e.w.Write(b)
+	return err
+}
+
+func (e *jsonExport) Flush() error {
+	return nil
+}
+
+func (e *jsonExport) End() error {
+	if e.lines {
+		return nil
+	}
+
+	_, err := io.WriteString(e.w, "]\n")
+	return err
+}
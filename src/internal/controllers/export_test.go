@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lekht/account-master/src/internal/hash"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage/mock"
+)
+
+func TestRouter_ExportUsers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	m := mock.New()
+	ids := make(map[string]uuid.UUID)
+	for _, name := range []string{"admin", "migrator"} {
+		pwd, _ := hash.HashPassword(context.Background(), name)
+		u, err := m.CreateUser(context.Background(), model.Profile{Username: name, Password: pwd, Admin: true})
+		if err != nil {
+			t.Fatalf("CreateUser() error = %v", err)
+		}
+		ids[name] = u.Id
+	}
+
+	// more than one page
+	for i := range exportPageSize + 10 {
+		name := fmt.Sprintf("user%04d", i)
+		if _, err := m.CreateUser(context.Background(), model.Profile{Username: name, Email: name + "@example.com", Password: "hash"}); err != nil {
+			t.Fatalf("CreateUser() error = %v", err)
+		}
+	}
+
+	const total = exportPageSize + 12
+
+	r := New(m, ExportHashes(ids["migrator"]))
+
+	tests := []struct {
+		name   string
+		user   string
+		query  string
+		status int
+		check  func(t *testing.T, body string)
+	}{
+		{
+			name:   "json",
+			user:   "admin",
+			status: http.StatusOK,
+			check: func(t *testing.T, body string) {
+				var rows []map[string]any
+				if err := json.Unmarshal([]byte(body), &rows); err != nil {
+					t.Fatalf("Unmarshal() error = %v", err)
+				}
+
+				if len(rows) != total {
+					t.Fatalf("rows = %d, want %d", len(rows), total)
+				}
+
+				if _, ok := rows[0][hashField]; ok {
+					t.Errorf("hash exported without include_hashes")
+				}
+			},
+		},
+		{
+			name:   "csv with fields",
+			user:   "admin",
+			query:  "?format=csv&fields=username,email",
+			status: http.StatusOK,
+			check: func(t *testing.T, body string) {
+				records, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+				if err != nil {
+					t.Fatalf("ReadAll() error = %v", err)
+				}
+
+				if len(records) != total+1 {
+					t.Fatalf("records = %d, want %d", len(records), total+1)
+				}
+
+				if got := strings.Join(records[0], ","); got != "username,email" {
+					t.Errorf("header = %q", got)
+				}
+
+				if got := strings.Join(records[total], ","); got != "user0509,user0509@example.com" {
+					t.Errorf("last record = %q", got)
+				}
+			},
+		},
+		{
+			name:   "ndjson with hashes",
+			user:   "migrator",
+			query:  "?format=ndjson&fields=username&include_hashes=true",
+			status: http.StatusOK,
+			check: func(t *testing.T, body string) {
+				lines := strings.Split(strings.TrimSpace(body), "\n")
+				if len(lines) != total {
+					t.Fatalf("lines = %d, want %d", len(lines), total)
+				}
+
+				if want := `{"username":"user0000","password_hash":"hash"}`; lines[2] != want {
+					t.Errorf("line = %s, want %s", lines[2], want)
+				}
+			},
+		},
+		{
+			name:   "hashes without permission",
+			user:   "admin",
+			query:  "?include_hashes=true",
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "unknown field",
+			user:   "admin",
+			query:  "?fields=password",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown format",
+			user:   "admin",
+			query:  "?format=xml",
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/user/export"+tt.query, nil)
+			req.SetBasicAuth(tt.user, tt.user)
+			w := httptest.NewRecorder()
+
+			r.Router().ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			if tt.check != nil {
+				tt.check(t, w.Body.String())
+			}
+		})
+	}
+
+	t.Run("grant is not inherited by username", func(t *testing.T) {
+		if err := m.DeleteUser(context.Background(), ids["migrator"]); err != nil {
+			t.Fatalf("DeleteUser() error = %v", err)
+		}
+
+		pwd, _ := hash.HashPassword(context.Background(), "migrator")
+		if _, err := m.CreateUser(context.Background(), model.Profile{Username: "migrator", Password: pwd, Admin: true}); err != nil {
+			t.Fatalf("CreateUser() error = %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/v1/user/export?include_hashes=true", nil)
+		req.SetBasicAuth("migrator", "migrator")
+		w := httptest.NewRecorder()
+
+		r.Router().ServeHTTP(w, req)
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+		}
+	})
+}
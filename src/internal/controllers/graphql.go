@@ -0,0 +1,458 @@
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/lekht/account-master/src/internal/audit"
+	"github.com/lekht/account-master/src/internal/hash"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+type GraphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// gqlError is resolver error with machine-readable code in extensions.
+type gqlError struct {
+	code    string
+	message string
+}
+
+func (e gqlError) Error() string { return e.message }
+
+func (e gqlError) Extensions() map[string]any { return map[string]any{"code": e.code} }
+
+// gqlValidationError reports validateProfile problem as BAD_USER_INPUT.
+func gqlValidationError(err error) error {
+	return gqlError{code: "BAD_USER_INPUT", message: problemFromError(err).Detail}
+}
+
+var (
+	errGQLForbidden = gqlError{code: "FORBIDDEN", message: "permission denied"}
+	errGQLInternal  = gqlError{code: "INTERNAL", message: "internal server error"}
+	errGQLNotFound  = gqlError{code: "NOT_FOUND", message: "user not found"}
+	errGQLInvalidID = gqlError{code: "BAD_USER_INPUT", message: "invalid user id"}
+	errGQLConflict  = gqlError{code: "CONFLICT", message: "user already exists"}
+)
+
+type ginContextKey struct{}
+
+// graphQL
+//
+//	@Summary		GraphQL
+//	@Description	GraphQL endpoint for users queries and mutations. Mutations are admin only
+//	@Security		BasicAuth
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			query	body	GraphQLRequest	true	"query, operationName, variables"
+//	@Success		200
+//	@Failure		400	{object}	Problem
+//	@Router			/graphql [post]
+func (r *Router) graphQL(c *gin.Context) {
+	var req GraphQLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []gin.H{{"message": "wrong json"}}})
+		return
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(req.Query)})})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []gin.H{{"message": err.Error()}}})
+		return
+	}
+
+	maxDepth, maxComplexity := r.graphQLLimits()
+	if err = checkQueryLimits(doc, req.OperationName, req.Variables, maxDepth, maxComplexity); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []gin.H{{"message": err.Error()}}})
+		return
+	}
+
+	res := graphql.Do(graphql.Params{
+		Schema:         r.schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        context.WithValue(c.Request.Context(), ginContextKey{}, c),
+	})
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (r *Router) newSchema() (graphql.Schema, error) {
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"id":       &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"email":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"username": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"admin":    &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		},
+	})
+
+	edgeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "UserEdge",
+		Fields: graphql.Fields{
+			"node":   &graphql.Field{Type: graphql.NewNonNull(userType)},
+			"cursor": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+
+	pageInfoType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PageInfo",
+		Fields: graphql.Fields{
+			"hasNextPage": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"endCursor":   &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	connectionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "UserConnection",
+		Fields: graphql.Fields{
+			"edges":      &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(edgeType)))},
+			"pageInfo":   &graphql.Field{Type: graphql.NewNonNull(pageInfoType)},
+			"totalCount": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+
+	filterType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "UserFilter",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"username": &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Substring of username"},
+			"email":    &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Substring of email"},
+			"admin":    &graphql.InputObjectFieldConfig{Type: graphql.Boolean},
+		},
+	})
+
+	createInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "CreateUserInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"email":    &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"username": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"password": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"admin":    &graphql.InputObjectFieldConfig{Type: graphql.Boolean, DefaultValue: false},
+		},
+	})
+
+	updateInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "UpdateUserInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"email":    &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"username": &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"password": &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"admin":    &graphql.InputObjectFieldConfig{Type: graphql.Boolean},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"users": &graphql.Field{
+				Type: graphql.NewNonNull(connectionType),
+				Args: graphql.FieldConfigArgument{
+					"filter": &graphql.ArgumentConfig{Type: filterType},
+					"first":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultPageSize},
+					"after":  &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: r.resolveUsers,
+			},
+			"user": &graphql.Field{
+				Type: userType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: r.resolveUser,
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createUser": &graphql.Field{
+				Type: graphql.NewNonNull(userType),
+				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(createInput)},
+				},
+				Resolve: r.resolveCreateUser,
+			},
+			"updateUser": &graphql.Field{
+				Type: graphql.NewNonNull(userType),
+				Args: graphql.FieldConfigArgument{
+					"id":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(updateInput)},
+				},
+				Resolve: r.resolveUpdateUser,
+			},
+			"deleteUser": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: r.resolveDeleteUser,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+}
+
+func (r *Router) resolveUsers(p graphql.ResolveParams) (any, error) {
+	first, _ := p.Args["first"].(int)
+	if first < 1 || first > maxPageSize {
+		return nil, gqlError{code: "BAD_USER_INPUT", message: "first must be between 1 and 100"}
+	}
+
+	users, err := r.repo.Users(p.Context)
+	if err != nil {
+		return nil, errGQLInternal
+	}
+
+	filter, _ := p.Args["filter"].(map[string]any)
+	matched := make([]model.Profile, 0, len(users))
+	for _, u := range users {
+		if matchUserFilter(u, filter) {
+			matched = append(matched, u)
+		}
+	}
+
+	start := 0
+	if after, ok := p.Args["after"].(string); ok {
+		name, err := base64.RawURLEncoding.DecodeString(after)
+		if err != nil {
+			return nil, gqlError{code: "BAD_USER_INPUT", message: "invalid cursor"}
+		}
+
+		// users are sorted by username, which is unique
+		for start < len(matched) && matched[start].Username <= string(name) {
+			start++
+		}
+	}
+
+	end := min(start+first, len(matched))
+
+	edges := make([]map[string]any, 0, end-start)
+	for _, u := range matched[start:end] {
+		edges = append(edges, map[string]any{
+			"node":   userToGraphQL(u),
+			"cursor": base64.RawURLEncoding.EncodeToString([]byte(u.Username)),
+		})
+	}
+
+	pageInfo := map[string]any{"hasNextPage": end < len(matched)}
+	if len(edges) > 0 {
+		pageInfo["endCursor"] = edges[len(edges)-1]["cursor"]
+	}
+
+	return map[string]any{
+		"edges":      edges,
+		"pageInfo":   pageInfo,
+		"totalCount": len(matched),
+	}, nil
+}
+
+func (r *Router) resolveUser(p graphql.ResolveParams) (any, error) {
+	id, err := uuid.Parse(p.Args["id"].(string))
+	if err != nil {
+		return nil, errGQLInvalidID
+	}
+
+	u, err := r.repo.UserByID(p.Context, id)
+	if errors.Is(err, storage.ErrNoUserID) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errGQLInternal
+	}
+
+	return userToGraphQL(u), nil
+}
+
+func (r *Router) resolveCreateUser(p graphql.ResolveParams) (any, error) {
+	c := ginContext(p.Context)
+	if !c.GetBool("isAdmin") {
+		return nil, errGQLForbidden
+	}
+
+	input := p.Args["input"].(map[string]any)
+
+	usr := model.Profile{
+		Email:    input["email"].(string),
+		Username: input["username"].(string),
+		Password: input["password"].(string),
+	}
+	usr.Admin, _ = input["admin"].(bool)
+
+	if err := validateProfile(usr, true); err != nil {
+		return nil, gqlValidationError(err)
+	}
+
+	var err error
+	if usr.Password, err = hash.HashPassword(p.Context, usr.Password); err != nil {
+		return nil, errGQLInternal
+	}
+
+	created, err := r.repo.CreateUser(p.Context, usr)
+	if errors.Is(err, storage.ErrUserExists) {
+		return nil, errGQLConflict
+	} else if err != nil {
+		return nil, errGQLInternal
+	}
+
+	r.recordAudit(c, audit.ActionCreate, created.Id, model.Profile{}, created)
+
+	return userToGraphQL(created), nil
+}
+
+func (r *Router) resolveUpdateUser(p graphql.ResolveParams) (any, error) {
+	c := ginContext(p.Context)
+	if !c.GetBool("isAdmin") {
+		return nil, errGQLForbidden
+	}
+
+	id, err := uuid.Parse(p.Args["id"].(string))
+	if err != nil {
+		return nil, errGQLInvalidID
+	}
+
+	input := p.Args["input"].(map[string]any)
+
+	password, hasPassword := input["password"].(string)
+	if hasPassword && isImpersonated(c) {
+		return nil, gqlError{code: "FORBIDDEN", message: "password can not be changed while impersonating"}
+	}
+
+	before, err := r.repo.UserByID(p.Context, id)
+	if errors.Is(err, storage.ErrNoUserID) {
+		return nil, errGQLNotFound
+	} else if err != nil {
+		return nil, errGQLInternal
+	}
+
+	// storage replaces whole profile, unset fields keep current values
+	upd := before
+	if email, ok := input["email"].(string); ok {
+		upd.Email = email
+	}
+
+	if username, ok := input["username"].(string); ok {
+		upd.Username = username
+	}
+
+	if admin, ok := input["admin"].(bool); ok {
+		upd.Admin = admin
+	}
+
+	if hasPassword {
+		upd.Password = password
+	}
+
+	// same rules as PUT and PATCH
+	if err = validateProfile(upd, hasPassword); err != nil {
+		return nil, gqlValidationError(err)
+	}
+
+	if hasPassword {
+		if upd.Password, err = hash.HashPassword(p.Context, password); err != nil {
+			return nil, errGQLInternal
+		}
+	}
+
+	err = r.repo.UpdateUser(p.Context, id, upd)
+	switch {
+	case errors.Is(err, storage.ErrNoUserID):
+		return nil, errGQLNotFound
+	case errors.Is(err, storage.ErrUserExists):
+		return nil, errGQLConflict
+	case err != nil:
+		return nil, errGQLInternal
+	}
+
+	after, err := r.repo.UserByID(p.Context, id)
+	if err != nil {
+		return nil, errGQLInternal
+	}
+
+	r.recordAudit(c, audit.ActionUpdate, id, before, after)
+
+	return userToGraphQL(after), nil
+}
+
+func (r *Router) resolveDeleteUser(p graphql.ResolveParams) (any, error) {
+	c := ginContext(p.Context)
+	if !c.GetBool("isAdmin") {
+		return nil, errGQLForbidden
+	}
+
+	id, err := uuid.Parse(p.Args["id"].(string))
+	if err != nil {
+		return nil, errGQLInvalidID
+	}
+
+	before, err := r.repo.UserByID(p.Context, id)
+	if errors.Is(err, storage.ErrNoUserID) {
+		return nil, errGQLNotFound
+	} else if err != nil {
+		return nil, errGQLInternal
+	}
+
+	err = r.repo.DeleteUser(p.Context, id)
+	if errors.Is(err, storage.ErrNoUserID) {
+		return nil, errGQLNotFound
+	} else if err != nil {
+		return nil, errGQLInternal
+	}
+
+	r.recordAudit(c, audit.ActionDelete, id, before, model.Profile{})
+
+	return true, nil
+}
+
+func ginContext(ctx context.Context) *gin.Context {
+	return ctx.Value(ginContextKey{}).(*gin.Context)
+}
+
+func matchUserFilter(u model.Profile, filter map[string]any) bool {
+	if v, ok := filter["username"].(string); ok && !strings.Contains(u.Username, v) {
+		return false
+	}
+
+	if v, ok := filter["email"].(string); ok && !strings.Contains(u.Email, v) {
+		return false
+	}
+
+	if v, ok := filter["admin"].(bool); ok && u.Admin != v {
+		return false
+	}
+
+	return true
+}
+
+func userToGraphQL(u model.Profile) map[string]any {
+	return map[string]any{
+		"id":       u.Id.String(),
+		"email":    u.Email,
+		"username": u.Username,
+		"admin":    u.Admin,
+	}
+}
+
+func clampPageSize(n int) int {
+	return min(max(n, 1), maxPageSize)
+}
@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+const (
+	defaultMaxDepth      = 8
+	defaultMaxComplexity = 200
+)
+
+var errUnknownOperation = errors.New("unknown operation")
+
+// queryCost returns depth and complexity of operation. Every field costs 1,
+// cost of fields with "first" argument children is multiplied by its value.
+// Introspection fields are counted the same way, so nested __schema or
+// __type queries are limited too.
+func queryCost(doc *ast.Document, operationName string, vars map[string]any) (depth, complexity int, err error) {
+	fragments := make(map[string]*ast.FragmentDefinition)
+	var op *ast.OperationDefinition
+
+	for _, def := range doc.Definitions {
+		switch d := def.(type) {
+		case *ast.FragmentDefinition:
+			fragments[d.Name.Value] = d
+		case *ast.OperationDefinition:
+			if operationName == "" || (d.Name != nil && d.Name.Value == operationName) {
+				if op == nil {
+					op = d
+				}
+			}
+		}
+	}
+
+	if op == nil {
+		return 0, 0, errUnknownOperation
+	}
+
+	a := analyzer{fragments: fragments, vars: vars, visiting: make(map[string]bool)}
+	depth, complexity = a.selectionSet(op.SelectionSet, 1)
+
+	return depth, complexity, nil
+}
+
+type analyzer struct {
+	fragments map[string]*ast.FragmentDefinition
+	vars      map[string]any
+	visiting  map[string]bool
+}
+
+func (a analyzer) selectionSet(set *ast.SelectionSet, level int) (depth, complexity int) {
+	if set == nil {
+		return 0, 0
+	}
+
+	for _, sel := range set.Selections {
+		var d, c int
+
+		switch s := sel.(type) {
+		case *ast.Field:
+			childDepth, childComplexity := a.selectionSet(s.SelectionSet, level+1)
+			d = max(level, childDepth)
+			c = 1 + childComplexity*a.multiplier(s)
+		case *ast.InlineFragment:
+			d, c = a.selectionSet(s.SelectionSet, level)
+		case *ast.FragmentSpread:
+			name := s.Name.Value
+			frag, ok := a.fragments[name]
+			// cycles are rejected by validation, guard only against endless loop
+			if !ok || a.visiting[name] {
+				continue
+			}
+
+			a.visiting[name] = true
+			d, c = a.selectionSet(frag.SelectionSet, level)
+			delete(a.visiting, name)
+		}
+
+		depth = max(depth, d)
+		complexity += c
+	}
+
+	return depth, complexity
+}
+
+func (a analyzer) multiplier(f *ast.Field) int {
+	for _, arg := range f.Arguments {
+		if arg.Name.Value != "first" {
+			continue
+		}
+
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(v.Value); err == nil {
+				return clampPageSize(n)
+			}
+		case *ast.Variable:
+			if n, ok := a.vars[v.Name.Value].(float64); ok {
+				return clampPageSize(int(n))
+			}
+		}
+	}
+
+	if f.Name.Value == "users" {
+		return defaultPageSize
+	}
+
+	return 1
+}
+
+func checkQueryLimits(doc *ast.Document, operationName string, vars map[string]any, maxDepth, maxComplexity int) error {
+	depth, complexity, err := queryCost(doc, operationName, vars)
+	if err != nil {
+		return err
+	}
+
+	if depth > maxDepth {
+		return fmt.Errorf("query depth %d exceeds limit %d", depth, maxDepth)
+	}
+
+	if complexity > maxComplexity {
+		return fmt.Errorf("query complexity %d exceeds limit %d", complexity, maxComplexity)
+	}
+
+	return nil
+}
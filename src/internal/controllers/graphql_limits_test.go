@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/lekht/account-master/src/internal/hash"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage/mock"
+)
+
+func TestQueryCost(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		vars           map[string]any
+		wantDepth      int
+		wantComplexity int
+	}{
+		{
+			name:           "single user",
+			query:          `{ user(id: "1") { id username } }`,
+			wantDepth:      2,
+			wantComplexity: 3,
+		},
+		{
+			name:           "page size multiplies children",
+			query:          `{ users(first: 10) { edges { node { id } } } }`,
+			wantDepth:      4,
+			wantComplexity: 1 + 10*3,
+		},
+		{
+			name:           "page size from variable",
+			query:          `query($n: Int) { users(first: $n) { totalCount } }`,
+			vars:           map[string]any{"n": float64(5)},
+			wantDepth:      2,
+			wantComplexity: 1 + 5,
+		},
+		{
+			name:           "default page size",
+			query:          `{ users { totalCount } }`,
+			wantDepth:      2,
+			wantComplexity: 1 + defaultPageSize,
+		},
+		{
+			name:           "fragments",
+			query:          `{ user(id: "1") { ...f } } fragment f on User { id ... on User { email } }`,
+			wantDepth:      2,
+			wantComplexity: 3,
+		},
+		{
+			name:           "introspection is counted",
+			query:          `{ __schema { types { fields { type { name } } } } user(id: "1") { id } }`,
+			wantDepth:      5,
+			wantComplexity: 5 + 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := parser.Parse(parser.ParseParams{Source: tt.query})
+			if err != nil {
+				t.Fatalf("parser.Parse() error = %v", err)
+			}
+
+			depth, complexity, err := queryCost(doc, "", tt.vars)
+			if err != nil {
+				t.Fatalf("queryCost() error = %v", err)
+			}
+
+			if depth != tt.wantDepth || complexity != tt.wantComplexity {
+				t.Errorf("queryCost() = %d, %d, want %d, %d", depth, complexity, tt.wantDepth, tt.wantComplexity)
+			}
+		})
+	}
+}
+
+func TestRouter_GraphQLLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	m := mock.New()
+	pwd, _ := hash.HashPassword(context.Background(), "admin")
+	if _, err := m.CreateUser(context.Background(), model.Profile{Username: "admin", Password: pwd, Admin: true}); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	r := New(m, GraphQLLimits(3, 50))
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		error  string
+	}{
+		{name: "within limits", query: `{ users(first: 5) { totalCount } }`, status: http.StatusOK},
+		{name: "too deep", query: `{ users(first: 1) { edges { node { id } } } }`, status: http.StatusBadRequest, error: "query depth 4 exceeds limit 3"},
+		{name: "introspection too deep", query: `{ __schema { types { fields { name } } } }`, status: http.StatusBadRequest, error: "query depth 4 exceeds limit 3"},
+		{name: "too complex", query: `{ users(first: 100) { totalCount } }`, status: http.StatusBadRequest, error: "query complexity 101 exceeds limit 50"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(GraphQLRequest{Query: tt.query})
+			req := httptest.NewRequest(http.MethodPost, "/v1/graphql", bytes.NewReader(body))
+			req.SetBasicAuth("admin", "admin")
+			w := httptest.NewRecorder()
+
+			r.Router().ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			if tt.error != "" && !strings.Contains(w.Body.String(), tt.error) {
+				t.Errorf("body = %s, want error %q", w.Body, tt.error)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lekht/account-master/src/internal/hash"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage/mock"
+)
+
+func TestRouter_GraphQLMutations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	m := mock.New()
+	pwd, _ := hash.HashPassword(context.Background(), "admin")
+	if _, err := m.CreateUser(context.Background(), model.Profile{Username: "admin", Password: pwd, Admin: true}); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	user, err := m.CreateUser(context.Background(), model.Profile{Email: "user@example.org", Username: "user", Password: pwd})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	r := New(m)
+
+	const update = `mutation($id: ID!, $input: UpdateUserInput!) { updateUser(id: $id, input: $input) { username } }`
+	const create = `mutation($input: CreateUserInput!) { createUser(input: $input) { username } }`
+
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]any
+		code      string
+	}{
+		{
+			name:      "update",
+			query:     update,
+			variables: map[string]any{"id": user.Id.String(), "input": map[string]any{"email": "user@example.com"}},
+		},
+		{
+			name:      "update to taken username",
+			query:     update,
+			variables: map[string]any{"id": user.Id.String(), "input": map[string]any{"username": "admin"}},
+			code:      "CONFLICT",
+		},
+		{
+			name:      "update to empty username",
+			query:     update,
+			variables: map[string]any{"id": user.Id.String(), "input": map[string]any{"username": " "}},
+			code:      "BAD_USER_INPUT",
+		},
+		{
+			name:      "update to invalid email",
+			query:     update,
+			variables: map[string]any{"id": user.Id.String(), "input": map[string]any{"email": "user"}},
+			code:      "BAD_USER_INPUT",
+		},
+		{
+			name:      "update to empty password",
+			query:     update,
+			variables: map[string]any{"id": user.Id.String(), "input": map[string]any{"password": ""}},
+			code:      "BAD_USER_INPUT",
+		},
+		{
+			name:      "create with empty username",
+			query:     create,
+			variables: map[string]any{"input": map[string]any{"email": "", "username": "", "password": "p"}},
+			code:      "BAD_USER_INPUT",
+		},
+		{
+			name:      "create with taken username",
+			query:     create,
+			variables: map[string]any{"input": map[string]any{"email": "", "username": "user", "password": "p"}},
+			code:      "CONFLICT",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(GraphQLRequest{Query: tt.query, Variables: tt.variables})
+			req := httptest.NewRequest(http.MethodPost, "/v1/graphql", bytes.NewReader(body))
+			req.SetBasicAuth("admin", "admin")
+			w := httptest.NewRecorder()
+
+			r.Router().ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+
+			var resp struct {
+				Errors []struct {
+					Extensions struct{ Code string }
+				}
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			code := ""
+			if len(resp.Errors) > 0 {
+				code = resp.Errors[0].Extensions.Code
+			}
+			if code != tt.code {
+				t.Errorf("error code = %q, want %q: %s", code, tt.code, w.Body)
+			}
+		})
+	}
+
+	stored, _ := m.UserByID(context.Background(), user.Id)
+	if stored.Username != "user" || stored.Email != "user@example.com" {
+		t.Errorf("user = %+v", stored)
+	}
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readyTimeout limits every readiness check.
+const readyTimeout = 2 * time.Second
+
+// Health statuses of probes and checks.
+const (
+	HealthOK          = "ok"
+	HealthUnavailable = "unavailable"
+	HealthDraining    = "draining"
+)
+
+// Pinger is dependency checked by readiness probe, e.g. storage backend.
+type Pinger interface {
+	Ping(context.Context) error
+}
+
+// HealthResponse of /healthz and /readyz.
+type HealthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// CheckResult of single readiness check. Probes are unauthenticated, so
+// error of failed check is logged and not returned.
+type CheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+}
+
+// Drain makes readiness probe fail, so traffic is moved away before
+// server stops. It is passed to server.OnShutdown.
+func (r *Router) Drain() {
+	r.draining.Store(true)
+}
+
+// healthz reports that process is alive. It checks no dependencies,
+// so orchestrator restarts only hung processes.
+func (r *Router) healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, HealthResponse{Status: HealthOK})
+}
+
+// readyz reports whether service can take traffic: every check passes
+// and server is not draining.
+func (r *Router) readyz(c *gin.Context) {
+	resp := HealthResponse{
+		Status: HealthOK,
+		Checks: make(map[string]CheckResult, len(r.healthChecks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, p := range r.healthChecks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			res, err := check(c.Request.Context(), p)
+			if err != nil {
+				requestLogger(c).Warn("readiness check failed", "check", name, "error", err)
+			}
+
+			mu.Lock()
+			resp.Checks[name] = res
+			if res.Status != HealthOK {
+				resp.Status = HealthUnavailable
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if r.draining.Load() {
+		resp.Status = HealthDraining
+	}
+
+	status := http.StatusOK
+	if resp.Status != HealthOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	c.JSON(status, resp)
+}
+
+func check(ctx context.Context, p Pinger) (CheckResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := p.Ping(ctx)
+	res := CheckResult{
+		Status:    HealthOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+
+	if err != nil {
+		res.Status = HealthUnavailable
+	}
+
+	return res, err
+}
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lekht/account-master/src/pkg/storage/mock"
+)
+
+type pingerFunc func(context.Context) error
+
+func (f pingerFunc) Ping(ctx context.Context) error { return f(ctx) }
+
+func TestRouter_Health(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	down := pingerFunc(func(context.Context) error { return errors.New("connection refused") })
+
+	tests := []struct {
+		name   string
+		path   string
+		checks map[string]Pinger
+		drain  bool
+		status int
+		want   HealthResponse
+	}{
+		{
+			name:   "alive",
+			path:   "/healthz",
+			checks: map[string]Pinger{"storage": down},
+			status: http.StatusOK,
+			want:   HealthResponse{Status: HealthOK},
+		},
+		{
+			name:   "ready",
+			path:   "/readyz",
+			checks: map[string]Pinger{"storage": mock.New()},
+			status: http.StatusOK,
+			want:   HealthResponse{Status: HealthOK, Checks: map[string]CheckResult{"storage": {Status: HealthOK}}},
+		},
+		{
+			name:   "storage down",
+			path:   "/readyz",
+			checks: map[string]Pinger{"storage": mock.New(), "cache": down},
+			status: http.StatusServiceUnavailable,
+			want: HealthResponse{Status: HealthUnavailable, Checks: map[string]CheckResult{
+				"storage": {Status: HealthOK},
+				"cache":   {Status: HealthUnavailable},
+			}},
+		},
+		{
+			name:   "draining",
+			path:   "/readyz",
+			checks: map[string]Pinger{"storage": mock.New()},
+			drain:  true,
+			status: http.StatusServiceUnavailable,
+			want:   HealthResponse{Status: HealthDraining, Checks: map[string]CheckResult{"storage": {Status: HealthOK}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			for name, p := range tt.checks {
+				opts = append(opts, HealthCheck(name, p))
+			}
+
+			r := New(mock.New(), opts...)
+			if tt.drain {
+				r.Drain()
+			}
+
+			w := httptest.NewRecorder()
+			r.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			var got HealthResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			if got.Status != tt.want.Status || len(got.Checks) != len(tt.want.Checks) {
+				t.Fatalf("response = %+v, want %+v", got, tt.want)
+			}
+
+			if strings.Contains(w.Body.String(), "connection refused") {
+				t.Errorf("response exposes check error: %s", w.Body)
+			}
+
+			for name, want := range tt.want.Checks {
+				res := got.Checks[name]
+				if res.Status != want.Status || res.LatencyMs < 0 {
+					t.Errorf("check %q = %+v, want %+v", name, res, want)
+				}
+			}
+		})
+	}
+}
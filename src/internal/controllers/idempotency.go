@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lekht/account-master/src/internal/model"
+)
+
+const (
+	idempotencyHeader  = "Idempotency-Key"
+	maxIdempotencyKey  = 255
+	defaultIdempotency = 24 * time.Hour
+	// maxIdempotentBody is limit of body read for fingerprint, the largest
+	// body of idempotent routes is import.
+	maxIdempotentBody = maxImportSize
+)
+
+// replayedHeaders are response headers kept with idempotency record.
+var replayedHeaders = []string{"Content-Type", "Location", "ETag"}
+
+type IdempotencyStore interface {
+	ReserveIdempotencyKey(rec model.IdempotencyRecord, now time.Time) (model.IdempotencyRecord, bool, error)
+	CompleteIdempotencyKey(rec model.IdempotencyRecord) error
+	DeleteIdempotencyKey(key string) error
+}
+
+// recordingWriter keeps copy of response body for idempotency record.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// idempotencyMiddleware replays stored response for repeated Idempotency-Key
+// of the same user. Key reused with different request gets 422, key of
+// request still in progress gets 409. Server errors are not stored, so
+// such requests can be retried with the same key.
+func (r *Router) idempotencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyHeader)
+		if r.idempotency == nil || key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKey {
+			abortWithError(c, newProblem(http.StatusBadRequest, CodeInvalidParameter, "Idempotency-Key is too long"))
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentBody))
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			abortWithError(c, newProblem(http.StatusRequestEntityTooLarge, CodeInvalidParameter,
+				fmt.Sprintf("request body is larger than %d bytes", maxIdempotentBody)))
+			return
+		} else if err != nil {
+			abortWithError(c, errInvalidJSON)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		now := time.Now()
+		rec := model.IdempotencyRecord{
+			Key:         c.MustGet("userID").(uuid.UUID).String() + " " + key,
+			Fingerprint: fingerprint(c.Request, body),
+			ExpiresAt:   now.Add(r.currentIdempotencyTTL()),
+		}
+
+		stored, reserved, err := r.idempotency.ReserveIdempotencyKey(rec, now)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		if !reserved {
+			replayIdempotent(c, stored, rec.Fingerprint)
+			return
+		}
+
+		release := func() {
+			if err := r.idempotency.DeleteIdempotencyKey(rec.Key); err != nil {
+				requestLogger(c).Error("failed to release idempotency key", "error", err)
+			}
+		}
+
+		// panic is recovered by outer middleware, key must not stay
+		// in progress until it expires
+		defer func() {
+			if p := recover(); p != nil {
+				release()
+				panic(p)
+			}
+		}()
+
+		w := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+
+		c.Next()
+
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			release()
+			return
+		}
+
+		rec.Completed = true
+		rec.Status = c.Writer.Status()
+		rec.Body = w.body.Bytes()
+		rec.Header = make(map[string][]string)
+		for _, h := range replayedHeaders {
+			if v := c.Writer.Header().Values(h); len(v) > 0 {
+				rec.Header[h] = v
+			}
+		}
+
+		if err = r.idempotency.CompleteIdempotencyKey(rec); err != nil {
+			requestLogger(c).Error("failed to store idempotent response", "error", err)
+		}
+	}
+}
+
+func replayIdempotent(c *gin.Context, stored model.IdempotencyRecord, fp string) {
+	switch {
+	case stored.Fingerprint != fp:
+		abortWithError(c, newProblem(http.StatusUnprocessableEntity, CodeIdempotencyMismatch,
+			"Idempotency-Key was used with different request"))
+	case !stored.Completed:
+		c.Header("Retry-After", "1")
+		abortWithError(c, newProblem(http.StatusConflict, CodeIdempotencyInProgress,
+			"request with this Idempotency-Key is in progress"))
+	default:
+		for h, v := range stored.Header {
+			for _, s := range v {
+				c.Writer.Header().Add(h, s)
+			}
+		}
+		c.Header("Idempotent-Replayed", "true")
+		c.Status(stored.Status)
+		_, _ = c.Writer.Write(stored.Body)
+		c.Abort()
+	}
+}
+
+// fingerprint of request: method, path with query and body.
+func fingerprint(req *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(req.Method + " " + req.URL.RequestURI() + "\n"))
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
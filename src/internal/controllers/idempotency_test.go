@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lekht/account-master/src/internal/hash"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage/mock"
+)
+
+func TestRouter_Idempotency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	m := mock.New()
+	pwd, _ := hash.HashPassword(context.Background(), "admin")
+	if _, err := m.CreateUser(context.Background(), model.Profile{Username: "admin", Password: pwd, Admin: true}); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	r := New(m, Idempotency(m, 0))
+
+	const body = `{"email":"a@b.c","username":"user","password":"p","admin":false}`
+
+	tests := []struct {
+		name     string
+		key      string
+		body     string
+		status   int
+		replayed bool
+	}{
+		{name: "first request", key: "k1", body: body, status: http.StatusCreated},
+		{name: "retry is replayed", key: "k1", body: body, status: http.StatusCreated, replayed: true},
+		{name: "retry without key", body: body, status: http.StatusConflict},
+		{name: "key with other body", key: "k1", body: `{"username":"other"}`, status: http.StatusUnprocessableEntity},
+		{name: "failed request is replayed", key: "k2", body: body, status: http.StatusConflict},
+		{name: "failed request retry", key: "k2", body: body, status: http.StatusConflict, replayed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/user", strings.NewReader(tt.body))
+			req.SetBasicAuth("admin", "admin")
+			if tt.key != "" {
+				req.Header.Set(idempotencyHeader, tt.key)
+			}
+			w := httptest.NewRecorder()
+
+			r.Router().ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			if got := w.Header().Get("Idempotent-Replayed") == "true"; got != tt.replayed {
+				t.Errorf("replayed = %v, want %v", got, tt.replayed)
+			}
+		})
+	}
+
+	users, _ := m.Users(context.Background())
+	if len(users) != 2 {
+		t.Errorf("users = %d, want 2", len(users))
+	}
+}
+
+func TestRouter_IdempotencyPanic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	m := mock.New()
+	pwd, _ := hash.HashPassword(context.Background(), "admin")
+	if _, err := m.CreateUser(context.Background(), model.Profile{Username: "admin", Password: pwd, Admin: true}); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	r := New(m, Idempotency(m, 0))
+
+	panics := true
+	r.Router().POST("/panic", r.basicAuthMiddleware(), r.idempotencyMiddleware(), func(c *gin.Context) {
+		if panics {
+			panic("boom")
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	do := func(body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/panic", strings.NewReader(body))
+		req.SetBasicAuth("admin", "admin")
+		req.Header.Set(idempotencyHeader, "k")
+		w := httptest.NewRecorder()
+
+		r.Router().ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if got := do("{}"); got != http.StatusInternalServerError {
+		t.Fatalf("panicking request status = %d, want %d", got, http.StatusInternalServerError)
+	}
+
+	panics = false
+	if got := do("{}"); got != http.StatusNoContent {
+		t.Errorf("retry status = %d, want %d", got, http.StatusNoContent)
+	}
+
+	if got := do(strings.Repeat(" ", maxIdempotentBody+1)); got != http.StatusRequestEntityTooLarge {
+		t.Errorf("large body status = %d, want %d", got, http.StatusRequestEntityTooLarge)
+	}
+}
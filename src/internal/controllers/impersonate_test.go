@@ -0,0 +1,215 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lekht/account-master/src/internal/hash"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage/mock"
+)
+
+func TestRouter_Impersonate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	m := mock.New()
+	ids := make(map[string]uuid.UUID)
+	for _, p := range []model.Profile{
+		{Email: "admin@example.org", Username: "admin", Admin: true},
+		{Email: "root@example.org", Username: "root", Admin: true},
+		{Email: "user@example.org", Username: "user"},
+	} {
+		p.Password, _ = hash.HashPassword(context.Background(), p.Username)
+		created, err := m.CreateUser(context.Background(), p)
+		if err != nil {
+			t.Fatalf("CreateUser() error = %v", err)
+		}
+		ids[p.Username] = created.Id
+	}
+
+	r := New(m)
+
+	// token issued by first step
+	var tok string
+
+	tests := []struct {
+		name   string
+		user   string
+		bearer bool
+		method string
+		path   string
+		status int
+		code   string
+		check  func(t *testing.T, body []byte)
+	}{
+		{
+			name: "admin impersonates user", user: "admin", method: http.MethodPost,
+			path:   "/v1/user/" + ids["user"].String() + "/impersonate",
+			status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				var resp ImpersonationResponse
+				if err := json.Unmarshal(body, &resp); err != nil {
+					t.Fatalf("Unmarshal() error = %v", err)
+				}
+				if resp.Token == "" || resp.Actor != ids["admin"] || resp.Subject != ids["user"] {
+					t.Errorf("impersonation = %+v", resp)
+				}
+				tok = resp.Token
+			},
+		},
+		{
+			name: "non-admin", user: "user", method: http.MethodPost,
+			path:   "/v1/user/" + ids["user"].String() + "/impersonate",
+			status: http.StatusForbidden, code: CodePermissionDenied,
+		},
+		{
+			name: "admin can not be impersonated", user: "admin", method: http.MethodPost,
+			path:   "/v1/user/" + ids["root"].String() + "/impersonate",
+			status: http.StatusForbidden, code: CodeImpersonationDenied,
+		},
+		{
+			name: "unknown user", user: "admin", method: http.MethodPost,
+			path:   "/v1/user/" + uuid.NewString() + "/impersonate",
+			status: http.StatusNotFound,
+		},
+		{
+			name: "invalid id", user: "admin", method: http.MethodPost,
+			path:   "/v1/user/42/impersonate",
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "token acts as subject", bearer: true, method: http.MethodGet,
+			path:   "/v1/user/" + ids["user"].String(),
+			status: http.StatusOK,
+		},
+		{
+			name: "token has no admin rights", bearer: true, method: http.MethodGet,
+			path:   "/v1/user/export",
+			status: http.StatusForbidden, code: CodePermissionDenied,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.bearer {
+				req.Header.Set("Authorization", "Bearer "+tok)
+			} else {
+				req.SetBasicAuth(tt.user, tt.user)
+			}
+			w := httptest.NewRecorder()
+
+			r.Router().ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			if tt.code != "" {
+				var p Problem
+				if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+					t.Fatalf("Unmarshal() error = %v", err)
+				}
+				if p.Code != tt.code {
+					t.Errorf("code = %q, want %q", p.Code, tt.code)
+				}
+			}
+
+			if tt.check != nil {
+				tt.check(t, w.Body.Bytes())
+			}
+		})
+	}
+}
+
+func TestRouter_ImpersonatedPassword(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	m := mock.New()
+	pwd, _ := hash.HashPassword(context.Background(), "admin")
+	admin, err := m.CreateUser(context.Background(), model.Profile{Email: "admin@example.org", Username: "admin", Password: pwd, Admin: true})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	r := New(m)
+
+	// impersonated subjects are never admins, so handlers are mounted
+	// directly to check they refuse password change on their own
+	impersonated := func(c *gin.Context) {
+		c.Set("impersonatorID", uuid.New())
+		c.Set("impersonator", "root")
+	}
+	r.Router().PUT("/impersonated/:id", r.basicAuthMiddleware(), impersonated, r.updateUserById)
+	r.Router().PATCH("/impersonated/:id", r.basicAuthMiddleware(), impersonated, r.patchUserById)
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		status      int
+	}{
+		{
+			name: "put password", method: http.MethodPut, contentType: "application/json",
+			body:   `{"email":"admin@example.org","username":"admin","password":"new","admin":true}`,
+			status: http.StatusForbidden,
+		},
+		{
+			name: "merge patch password", method: http.MethodPatch, contentType: "application/merge-patch+json",
+			body:   `{"password":"new"}`,
+			status: http.StatusForbidden,
+		},
+		{
+			name: "json patch password", method: http.MethodPatch, contentType: "application/json-patch+json",
+			body:   `[{"op":"add","path":"/password","value":"new"}]`,
+			status: http.StatusForbidden,
+		},
+		{
+			name: "put without password", method: http.MethodPut, contentType: "application/json",
+			body:   `{"email":"admin@example.org","username":"admin","admin":true}`,
+			status: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/impersonated/"+admin.Id.String(), strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req.SetBasicAuth("admin", "admin")
+			w := httptest.NewRecorder()
+
+			r.Router().ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			if tt.status != http.StatusForbidden {
+				return
+			}
+
+			var p Problem
+			if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if p.Code != CodeImpersonationDenied {
+				t.Errorf("code = %q, want %q", p.Code, CodeImpersonationDenied)
+			}
+		})
+	}
+
+	stored, err := m.UserByID(context.Background(), admin.Id)
+	if err != nil {
+		t.Fatalf("UserByID() error = %v", err)
+	}
+	if ok, _ := hash.CheckPassword(context.Background(), "admin", stored.Password); !ok {
+		t.Error("password changed while impersonating")
+	}
+}
@@ -0,0 +1,408 @@
+package controllers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lekht/account-master/src/internal/audit"
+	"github.com/lekht/account-master/src/internal/hash"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage"
+)
+
+const (
+	csvType    = "text/csv"
+	ndjsonType = "application/x-ndjson"
+
+	maxImportSize = 10 << 20
+	maxImportRows = 10000
+	// maxImportPlaintext bounds bcrypt work of single import, about 100ms
+	// of CPU per password. Larger migrations send bcrypt hashes.
+	maxImportPlaintext = 100
+)
+
+// Statuses of import rows.
+const (
+	ImportCreated = "created"
+	ImportValid   = "valid" // row would be created, reported by dry run
+	ImportSkipped = "skipped"
+	ImportError   = "error"
+)
+
+// ImportRow is result of single imported row. Line is line number
+// in uploaded file.
+type ImportRow struct {
+	Line     int       `json:"line"`
+	Username string    `json:"username,omitempty"`
+	Status   string    `json:"status"`
+	Id       uuid.UUID `json:"id,omitzero"`
+	Error    string    `json:"error,omitempty"`
+}
+
+type ImportReport struct {
+	DryRun  bool        `json:"dry_run"`
+	OnError string      `json:"on_error"`
+	Created int         `json:"created"`
+	Skipped int         `json:"skipped"`
+	Failed  int         `json:"failed"`
+	Rows    []ImportRow `json:"rows"`
+}
+
+// importRecord is row of uploaded file. Password is plaintext or bcrypt hash.
+type importRecord struct {
+	Email    string `json:"email"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Admin    bool   `json:"admin"`
+}
+
+// errImportFailed stops unit of work after failed row in abort mode.
+var errImportFailed = errors.New("import row failed")
+
+type parsedRow struct {
+	line    int
+	profile model.Profile
+	err     error
+}
+
+// importUsers()
+//
+//	@Summary		Import Users
+//	@Description	Create users from CSV (header: email,username,password,admin) or NDJSON.
+//	@Description	Passwords are plaintext (at most 100 per import) or bcrypt hashes. With on_error=abort nothing is created
+//	@Description	if any row is invalid or fails to be created, with on_error=skip failed rows are reported and skipped
+//	@Security		BasicAuth
+//	@Accept			text/csv
+//	@Accept			application/x-ndjson
+//	@Produce		json
+//	@Param			dry_run			query	bool	false	"Validate rows without creating users"
+//	@Param			on_error		query	string	false	"abort (default) or skip"
+//	@Param			Idempotency-Key	header	string	false	"Key to safely retry request"
+//	@Success		200	{object}	ImportReport
+//	@Failure		400	{object}	Problem
+//	@Failure		403	{object}	Problem
+//	@Failure		413	{object}	Problem
+//	@Failure		415	{object}	Problem
+//	@Router			/user/import [post]
+func (r *Router) importUsers(c *gin.Context) {
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		abortWithError(c, newProblem(http.StatusBadRequest, CodeInvalidParameter, "invalid dry_run"))
+		return
+	}
+
+	onError := c.DefaultQuery("on_error", "abort")
+	if onError != "abort" && onError != "skip" {
+		abortWithError(c, newProblem(http.StatusBadRequest, CodeInvalidParameter, "on_error must be abort or skip"))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize))
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		abortWithError(c, newProblem(http.StatusRequestEntityTooLarge, CodeInvalidParameter,
+			fmt.Sprintf("import is larger than %d bytes", maxImportSize)))
+		return
+	} else if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	var rows []parsedRow
+	switch c.ContentType() {
+	case csvType:
+		rows, err = parseCSV(body)
+	case ndjsonType:
+		rows, err = parseNDJSON(body)
+	default:
+		abortWithError(c, newProblem(http.StatusUnsupportedMediaType, CodeUnsupportedMedia,
+			"content type must be "+csvType+" or "+ndjsonType))
+		return
+	}
+
+	if err != nil {
+		abortWithError(c, newProblem(http.StatusBadRequest, CodeInvalidParameter, err.Error()))
+		return
+	}
+
+	if len(rows) > maxImportRows {
+		abortWithError(c, newProblem(http.StatusRequestEntityTooLarge, CodeInvalidParameter,
+			fmt.Sprintf("import has more than %d rows", maxImportRows)))
+		return
+	}
+
+	plaintext := 0
+	for _, row := range rows {
+		if row.err == nil && !hash.IsHash(row.profile.Password) {
+			plaintext++
+		}
+	}
+	if plaintext > maxImportPlaintext {
+		abortWithError(c, newProblem(http.StatusRequestEntityTooLarge, CodeInvalidParameter,
+			fmt.Sprintf("import has more than %d plaintext passwords, send bcrypt hashes instead", maxImportPlaintext)))
+		return
+	}
+
+	report := ImportReport{DryRun: dryRun, OnError: onError, Rows: make([]ImportRow, len(rows))}
+
+	// rows are validated before any user is created, so abort mode
+	// does not leave half of file imported
+	seen := make(map[string]bool, len(rows))
+	for i := range rows {
+		row := &rows[i]
+		if row.err == nil {
+			row.err = r.validateImport(c.Request.Context(), row.profile, seen)
+		}
+		seen[row.profile.Username] = true
+
+		report.Rows[i] = ImportRow{Line: row.line, Username: row.profile.Username}
+		if row.err != nil {
+			report.Rows[i].Status = ImportError
+			report.Rows[i].Error = row.err.Error()
+			report.Failed++
+		}
+	}
+
+	abort := onError == "abort" && report.Failed > 0
+
+	// passwords are hashed before unit of work, which may hold storage lock
+	for i := range rows {
+		row, res := &rows[i], &report.Rows[i]
+		if res.Status == ImportError {
+			continue
+		}
+
+		switch {
+		case abort:
+			res.Status = ImportSkipped
+			report.Skipped++
+			continue
+		case dryRun:
+			res.Status = ImportValid
+			continue
+		}
+
+		if !hash.IsHash(row.profile.Password) {
+			if row.profile.Password, err = hash.HashPassword(c.Request.Context(), row.profile.Password); err != nil {
+				abortWithError(c, err)
+				return
+			}
+		}
+	}
+
+	if abort || dryRun {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	created := make([]model.Profile, 0, len(rows))
+
+	run := func(tx storage.Tx) error {
+		for i, row := range rows {
+			res := &report.Rows[i]
+			if res.Status == ImportError {
+				continue
+			}
+
+			u, err := tx.CreateUser(c.Request.Context(), row.profile)
+			if ctxErr := c.Request.Context().Err(); ctxErr != nil {
+				return ctxErr
+			} else if err != nil {
+				res.Status = ImportError
+				res.Error = problemFromError(err).Detail
+				report.Failed++
+
+				if onError == "abort" {
+					return errImportFailed
+				}
+				continue
+			}
+
+			res.Status = ImportCreated
+			res.Id = u.Id
+			report.Created++
+			created = append(created, u)
+		}
+
+		return nil
+	}
+
+	// abort mode is all-or-nothing when repository supports transactions,
+	// otherwise users created before failed row are kept
+	if txRepo, ok := r.repo.(TxRepository); ok && onError == "abort" {
+		err = txRepo.WithinTx(c.Request.Context(), run)
+	} else {
+		err = run(r.repo)
+	}
+
+	switch {
+	case errors.Is(err, errImportFailed):
+		if _, ok := r.repo.(TxRepository); ok {
+			for i := range report.Rows {
+				if res := &report.Rows[i]; res.Status == ImportCreated {
+					res.Status, res.Id = ImportSkipped, uuid.Nil
+					report.Created--
+					report.Skipped++
+				}
+			}
+			created = nil
+		}
+
+		for i := range report.Rows {
+			if res := &report.Rows[i]; res.Status == "" {
+				res.Status = ImportSkipped
+				report.Skipped++
+			}
+		}
+	case err != nil:
+		// client is gone or server timed out, report is not delivered
+		abortWithError(c, err)
+		return
+	}
+
+	for _, u := range created {
+		r.recordAudit(c, audit.ActionCreate, u.Id, model.Profile{}, u)
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// validateImport checks row and reports usernames taken by stored users
+// or earlier rows of the same file.
+func (r *Router) validateImport(ctx context.Context, p model.Profile, seen map[string]bool) error {
+	if err := validateProfile(p, true); err != nil {
+		return errors.New(problemFromError(err).Detail)
+	}
+
+	if seen[p.Username] {
+		return errors.New("duplicate username in import")
+	}
+
+	_, err := r.repo.UserByName(ctx, p.Username)
+	switch {
+	case err == nil:
+		return errors.New("user already exists")
+	case errors.Is(err, storage.ErrNoUsername):
+		return nil
+	default:
+		return err
+	}
+}
+
+func recordToProfile(rec importRecord) model.Profile {
+	return model.Profile{
+		Email:    rec.Email,
+		Username: rec.Username,
+		Password: rec.Password,
+		Admin:    rec.Admin,
+	}
+}
+
+// parseCSV reads rows of CSV with header. Columns are matched by name,
+// username and password are required, email and admin are optional.
+func parseCSV(body []byte) ([]parsedRow, error) {
+	cr := csv.NewReader(bytes.NewReader(body))
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for _, name := range []string{"username", "password"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("csv header has no %s column", name)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	var rows []parsedRow
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		// FieldPos panics after failed Read, line of error is in ParseError
+		var perr *csv.ParseError
+		if errors.As(err, &perr) && errors.Is(perr.Err, csv.ErrFieldCount) {
+			// row with wrong number of fields is reported, other rows are kept
+			rows = append(rows, parsedRow{line: perr.StartLine, err: errors.New("wrong number of fields")})
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read csv: %w", err)
+		}
+
+		line, _ := cr.FieldPos(0)
+		row := parsedRow{line: line}
+		row.profile = recordToProfile(importRecord{
+			Email:    field(record, "email"),
+			Username: field(record, "username"),
+			Password: field(record, "password"),
+		})
+
+		if v := strings.TrimSpace(field(record, "admin")); v != "" {
+			if row.profile.Admin, err = strconv.ParseBool(v); err != nil {
+				row.err = fmt.Errorf("invalid admin value %q", v)
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// parseNDJSON reads one JSON object per line. Blank lines are ignored.
+func parseNDJSON(body []byte) ([]parsedRow, error) {
+	sc := bufio.NewScanner(bytes.NewReader(body))
+	sc.Buffer(make([]byte, 0, 64*1024), maxImportSize)
+
+	var rows []parsedRow
+	for line := 1; sc.Scan(); line++ {
+		b := bytes.TrimSpace(sc.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+
+		var rec importRecord
+		row := parsedRow{line: line}
+		if err := dec.Decode(&rec); err != nil {
+			row.err = fmt.Errorf("invalid json: %v", err)
+		}
+		row.profile = recordToProfile(rec)
+
+		rows = append(rows, row)
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ndjson: %w", err)
+	}
+
+	return rows, nil
+}
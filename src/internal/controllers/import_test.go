@@ -0,0 +1,266 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lekht/account-master/src/internal/hash"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage"
+	"github.com/lekht/account-master/src/pkg/storage/mock"
+)
+
+func TestRouter_ImportUsers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	bcrypted, _ := hash.HashPassword(context.Background(), "migrated")
+
+	const csvBody = "email,username,password,admin\n" +
+		"a@example.com,alice,secret,false\n" +
+		"b@example.com,admin,secret,true\n" +
+		"c@example.com,carol,secret,maybe\n"
+
+	ndjsonBody := `{"email":"a@example.com","username":"alice","password":"secret"}` + "\n" +
+		"\n" +
+		`{"username":"bob","password":"` + bcrypted + `","admin":true}` + "\n"
+
+	tests := []struct {
+		name        string
+		query       string
+		contentType string
+		body        string
+		status      int
+		want        []string
+		created     []string
+	}{
+		{
+			name:        "csv abort",
+			contentType: "text/csv",
+			body:        csvBody,
+			status:      http.StatusOK,
+			want:        []string{ImportSkipped, ImportError, ImportError},
+		},
+		{
+			name:        "csv skip",
+			query:       "?on_error=skip",
+			contentType: "text/csv",
+			body:        csvBody,
+			status:      http.StatusOK,
+			want:        []string{ImportCreated, ImportError, ImportError},
+			created:     []string{"alice"},
+		},
+		{
+			name:        "ndjson dry run",
+			query:       "?dry_run=true",
+			contentType: "application/x-ndjson",
+			body:        ndjsonBody,
+			status:      http.StatusOK,
+			want:        []string{ImportValid, ImportValid},
+		},
+		{
+			name:        "ndjson",
+			contentType: "application/x-ndjson",
+			body:        ndjsonBody,
+			status:      http.StatusOK,
+			want:        []string{ImportCreated, ImportCreated},
+			created:     []string{"alice", "bob"},
+		},
+		{
+			name:        "csv without password column",
+			contentType: "text/csv",
+			body:        "username\nalice\n",
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "too many plaintext passwords",
+			contentType: "application/x-ndjson",
+			body:        strings.Repeat(`{"username":"u","password":"p"}`+"\n", maxImportPlaintext+1),
+			status:      http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:        "csv with bare quote",
+			contentType: "text/csv",
+			body:        "email,username,password,admin\n" + `a"@example.com,alice,secret,false` + "\n",
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "csv with unterminated quote",
+			contentType: "text/csv",
+			body:        "email,username,password,admin\n" + `"a@example.com,alice,secret,false` + "\n",
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "unsupported type",
+			contentType: "application/json",
+			body:        "[]",
+			status:      http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mock.New()
+			pwd, _ := hash.HashPassword(context.Background(), "admin")
+			_, _ = m.CreateUser(context.Background(), model.Profile{Username: "admin", Password: pwd, Admin: true})
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/user/import"+tt.query, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req.SetBasicAuth("admin", "admin")
+			w := httptest.NewRecorder()
+
+			New(m).Router().ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var report ImportReport
+			if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			if len(report.Rows) != len(tt.want) {
+				t.Fatalf("rows = %+v, want statuses %v", report.Rows, tt.want)
+			}
+
+			for i, row := range report.Rows {
+				if row.Status != tt.want[i] {
+					t.Errorf("row %d status = %q (%s), want %q", row.Line, row.Status, row.Error, tt.want[i])
+				}
+			}
+
+			users, _ := m.Users(context.Background())
+			if len(users) != len(tt.created)+1 {
+				t.Errorf("users = %d, want %d", len(users), len(tt.created)+1)
+			}
+
+			for _, name := range tt.created {
+				u, err := m.UserByName(context.Background(), name)
+				if err != nil {
+					t.Fatalf("UserByName(%q) error = %v", name, err)
+				}
+
+				password := map[string]string{"alice": "secret", "bob": "migrated"}[name]
+				if ok, _ := hash.CheckPassword(context.Background(), password, u.Password); !ok {
+					t.Errorf("password of %q is not stored as bcrypt hash of input", name)
+				}
+			}
+		})
+	}
+}
+
+// failingCreate fails to create user named fail after it passed validation,
+// e.g. when concurrent request took the username.
+type failingCreate struct {
+	storage.Tx
+	fail string
+}
+
+func (f failingCreate) CreateUser(ctx context.Context, p model.Profile) (model.Profile, error) {
+	if p.Username == f.fail {
+		return model.Profile{}, storage.ErrUserExists
+	}
+
+	return f.Tx.CreateUser(ctx, p)
+}
+
+// failingRepo is repository without transactions that fails like
+// failingCreate.
+type failingRepo struct {
+	Repository
+	fail string
+}
+
+func (f failingRepo) CreateUser(ctx context.Context, p model.Profile) (model.Profile, error) {
+	return failingCreate{Tx: f.Repository, fail: f.fail}.CreateUser(ctx, p)
+}
+
+// failingTxRepo runs every unit of work of mock through failingCreate.
+type failingTxRepo struct {
+	*mock.Mock
+	fail string
+}
+
+func (f failingTxRepo) WithinTx(ctx context.Context, fn func(storage.Tx) error) error {
+	return f.Mock.WithinTx(ctx, func(tx storage.Tx) error {
+		return fn(failingCreate{Tx: tx, fail: f.fail})
+	})
+}
+
+func TestRouter_ImportAbort(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const body = "email,username,password,admin\n" +
+		"a@example.com,alice,secret,false\n" +
+		"b@example.com,bob,secret,false\n" +
+		"c@example.com,carol,secret,false\n"
+
+	tests := []struct {
+		name    string
+		repo    func(m *mock.Mock) Repository
+		want    []string
+		created []string
+	}{
+		{
+			name: "transaction rolls back",
+			repo: func(m *mock.Mock) Repository { return failingTxRepo{Mock: m, fail: "bob"} },
+			want: []string{ImportSkipped, ImportError, ImportSkipped},
+		},
+		{
+			name:    "no transactions keeps created",
+			repo:    func(m *mock.Mock) Repository { return failingRepo{Repository: m, fail: "bob"} },
+			want:    []string{ImportCreated, ImportError, ImportSkipped},
+			created: []string{"alice"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mock.New()
+			pwd, _ := hash.HashPassword(context.Background(), "admin")
+			_, _ = m.CreateUser(context.Background(), model.Profile{Username: "admin", Password: pwd, Admin: true})
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/user/import", strings.NewReader(body))
+			req.Header.Set("Content-Type", "text/csv")
+			req.SetBasicAuth("admin", "admin")
+			w := httptest.NewRecorder()
+
+			New(tt.repo(m)).Router().ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+
+			var report ImportReport
+			if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			got := make([]string, 0, len(report.Rows))
+			for _, row := range report.Rows {
+				got = append(got, row.Status)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("statuses = %v, want %v", got, tt.want)
+			}
+
+			if report.Created != len(tt.created) {
+				t.Errorf("created = %d, want %d", report.Created, len(tt.created))
+			}
+
+			users, _ := m.Users(context.Background())
+			if len(users) != len(tt.created)+1 {
+				t.Errorf("users = %+v, want admin and %v", users, tt.created)
+			}
+		})
+	}
+}
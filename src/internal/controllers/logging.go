@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lekht/account-master/src/internal/logger"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxRequestIDLen limits length of X-Request-ID taken from client.
+const maxRequestIDLen = 128
+
+// probeRoutes are polled by orchestrator, their successful requests
+// are logged at debug level.
+var probeRoutes = map[string]bool{"/healthz": true, "/readyz": true}
+
+// requestID returns id of current request. It is taken from X-Request-ID
+// header or generated once per request.
+func requestID(c *gin.Context) string {
+	if id := c.GetString("requestID"); id != "" {
+		return id
+	}
+
+	id := c.GetHeader("X-Request-ID")
+	if !validRequestID(id) {
+		id = uuid.NewString()
+	}
+
+	c.Set("requestID", id)
+	c.Header("X-Request-ID", id)
+
+	return id
+}
+
+// validRequestID accepts non-empty printable ASCII ids of sane length.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
+
+// loggingMiddleware assigns request id and puts request logger into request
+// context. When request is done it logs one line with route, status,
+// latency and authenticated user. Headers and bodies are never logged.
+func (r *Router) loggingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		l := r.logger.With("request_id", requestID(c))
+		if sc := trace.SpanContextFromContext(c.Request.Context()); sc.IsValid() {
+			l = l.With("trace_id", sc.TraceID().String())
+		}
+		c.Request = c.Request.WithContext(logger.NewContext(c.Request.Context(), l))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		case probeRoutes[c.FullPath()]:
+			level = slog.LevelDebug
+		}
+
+		requestLogger(c).LogAttrs(c.Request.Context(), level, "request",
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("size", c.Writer.Size()),
+		)
+	}
+}
+
+// requestLogger returns logger of current request with route, user and
+// identity of client certificate.
+func requestLogger(c *gin.Context) *slog.Logger {
+	l := logger.FromContext(c.Request.Context()).With("route", c.FullPath())
+
+	if user := c.GetString("username"); user != "" {
+		l = l.With("user", user)
+	}
+
+	if imp := c.GetString("impersonator"); imp != "" {
+		l = l.With("impersonator", imp)
+	}
+
+	if cert := c.GetString("certificate"); cert != "" {
+		l = l.With("certificate", cert)
+	}
+
+	return l
+}
+
+// recoveryMiddleware logs panics of handlers and responds with problem.
+func recoveryMiddleware() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, err any) {
+		requestLogger(c).Error("panic recovered", "error", err)
+		abortWithError(c, errInternal)
+	})
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lekht/account-master/src/internal/hash"
+	"github.com/lekht/account-master/src/internal/logger"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage/mock"
+)
+
+func TestRouter_Logging(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	m := mock.New()
+	pwd, _ := hash.HashPassword(context.Background(), "admin")
+	_, _ = m.CreateUser(context.Background(), model.Profile{Username: "admin", Password: pwd, Admin: true})
+
+	tests := []struct {
+		name      string
+		requestID string
+		body      string
+		status    int
+		user      string
+	}{
+		{name: "propagated id", requestID: "req-1", body: `{"username":"alice","password":"topsecret"}`, status: http.StatusCreated, user: "admin"},
+		{name: "generated id", body: `{"username":"alice","password":"topsecret"}`, status: http.StatusConflict, user: "admin"},
+		{name: "invalid id replaced", requestID: "bad id\n", body: `{`, status: http.StatusBadRequest, user: "admin"},
+	}
+
+	var buf bytes.Buffer
+	l, _ := logger.New(&buf, "debug", "json")
+	r := New(m, Logger(l))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/user", strings.NewReader(tt.body))
+			req.SetBasicAuth("admin", "admin")
+			if tt.requestID != "" {
+				req.Header.Set("X-Request-ID", tt.requestID)
+			}
+			w := httptest.NewRecorder()
+
+			r.Router().ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			id := w.Header().Get("X-Request-ID")
+			if id == "" || (id == tt.requestID) != validRequestID(tt.requestID) {
+				t.Errorf("X-Request-ID = %q, sent %q", id, tt.requestID)
+			}
+
+			if strings.Contains(buf.String(), "topsecret") || strings.Contains(buf.String(), req.Header.Get("Authorization")) {
+				t.Fatalf("credentials logged: %s", buf.String())
+			}
+
+			var line struct {
+				Msg       string
+				RequestID string `json:"request_id"`
+				Route     string
+				User      string
+				Status    int
+				Latency   int64
+			}
+			if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+				t.Fatalf("Unmarshal() error = %v: %s", err, buf.String())
+			}
+
+			if line.Msg != "request" || line.RequestID != id || line.Route != "/v1/user" ||
+				line.User != tt.user || line.Status != tt.status || line.Latency <= 0 {
+				t.Errorf("log line = %+v", line)
+			}
+		})
+	}
+}
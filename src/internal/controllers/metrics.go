@@ -0,0 +1,27 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unmatchedRoute labels requests without route, so unknown paths
+// do not create new label values.
+const unmatchedRoute = "unmatched"
+
+// metricsMiddleware records every request by route template and status.
+func (r *Router) metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+
+		r.metrics.ObserveRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
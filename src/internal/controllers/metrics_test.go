@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lekht/account-master/src/internal/hash"
+	"github.com/lekht/account-master/src/internal/metrics"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage/mock"
+)
+
+func TestRouter_Metrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	m := mock.New()
+	pwd, _ := hash.HashPassword(context.Background(), "admin")
+	admin, _ := m.CreateUser(context.Background(), model.Profile{Username: "admin", Password: pwd, Admin: true})
+
+	mtr := metrics.New()
+	r := New(m, Metrics(mtr))
+
+	requests := []struct {
+		path     string
+		password string
+	}{
+		{path: "/v1/user/" + admin.Id.String(), password: "admin"},
+		{path: "/v1/user/" + admin.Id.String(), password: "wrong"},
+		{path: "/v1/nowhere/" + admin.Id.String(), password: "admin"},
+	}
+
+	for _, req := range requests {
+		httpReq := httptest.NewRequest(http.MethodGet, req.path, nil)
+		httpReq.SetBasicAuth("admin", req.password)
+		r.Router().ServeHTTP(httptest.NewRecorder(), httpReq)
+	}
+
+	w := httptest.NewRecorder()
+	mtr.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	for _, want := range []string{
+		`account_master_http_requests_total{method="GET",route="/v1/user/:id",status="200"} 1`,
+		`account_master_http_requests_total{method="GET",route="/v1/user/:id",status="401"} 1`,
+		`account_master_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`account_master_auth_attempts_total{method="basic",reason="",result="success"} 1`,
+		`account_master_auth_attempts_total{method="basic",reason="invalid_credentials",result="failure"} 1`,
+		`account_master_password_check_duration_seconds_count 2`,
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("metrics miss %s", want)
+		}
+	}
+}
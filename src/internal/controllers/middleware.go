@@ -5,70 +5,83 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/lekht/account-master/src/internal/hash"
-	"github.com/lekht/account-master/src/pkg/storage/mock"
+	"github.com/lekht/account-master/src/internal/auth"
 )
 
 func (r *Router) basicAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		username, password, ok := c.Request.BasicAuth()
-		if !ok {
+		id, err := r.authenticate(c)
+		switch {
+		case errors.Is(err, auth.ErrNoCredentials):
 			c.Header("WWW-Authenticate", `Basic realm="Restricted"`)
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "Authentication required",
-			})
+			abortWithError(c, newProblem(http.StatusUnauthorized, CodeAuthRequired, "authentication required"))
 			return
-		}
-
-		user, err := r.repo.UserByName(username)
-		if errors.Is(err, mock.ErrNoUsername) {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "Invalid username or password",
-			})
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			abortWithError(c, newProblem(http.StatusUnauthorized, CodeInvalidCredentials, "invalid username or password"))
+			return
+		case errors.Is(err, auth.ErrInvalidToken):
+			abortWithError(c, newProblem(http.StatusUnauthorized, CodeInvalidToken, "invalid token"))
 			return
-		} else if err != nil {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-				"error": "Internal server error",
-			})
+		case errors.Is(err, auth.ErrInvalidCertificate):
+			abortWithError(c, newProblem(http.StatusUnauthorized, CodeInvalidCertificate, "no account for client certificate"))
+			return
+		case err != nil:
+			abortWithError(c, err)
 			return
 		}
 
-		isSame, err := hash.CheckPassword(password, user.Password)
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-				"error": err.Error(),
-			})
-			return
+		c.Set("userID", id.UserID)
+		c.Set("username", id.Username)
+		c.Set("isAdmin", id.Admin)
+		if id.Certificate != "" {
+			c.Set("certificate", id.Certificate)
 		}
 
-		// if user.Password != pwdHash {
-		if !isSame {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "Invalid username or password",
-			})
+		if !id.Impersonated() {
+			c.Next()
 			return
 		}
 
-		c.Set("username", user.Username)
-		c.Set("isAdmin", user.Admin)
+		c.Set("impersonatorID", id.ImpersonatorID)
+		c.Set("impersonator", id.Impersonator)
 
 		c.Next()
+
+		requestLogger(c).Info("impersonated request",
+			"impersonator_id", id.ImpersonatorID, "user_id", id.UserID,
+			"method", c.Request.Method, "status", c.Writer.Status())
 	}
 }
 
+// authenticate checks Authorization header or, when it is missing,
+// verified client certificate.
+func (r *Router) authenticate(c *gin.Context) (auth.Identity, error) {
+	header := c.GetHeader("Authorization")
+	if tls := c.Request.TLS; header == "" && tls != nil && len(tls.VerifiedChains) > 0 {
+		return r.auth.Certificate(c.Request.Context(), tls.VerifiedChains[0][0])
+	}
+
+	return r.auth.Authorization(c.Request.Context(), header)
+}
+
 func isAdminMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		isAdmin, ok := c.Get("isAdmin")
 		if !ok {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
+			abortWithError(c, newProblem(http.StatusUnauthorized, CodeAuthRequired, "authentication required"))
 			return
 		}
 
 		if !isAdmin.(bool) {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+			abortWithError(c, errForbidden)
 			return
 		}
 
 		c.Next()
 	}
 }
+
+func isImpersonated(c *gin.Context) bool {
+	_, ok := c.Get("impersonatorID")
+	return ok
+}
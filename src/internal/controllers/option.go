@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lekht/account-master/src/internal/audit"
+	"github.com/lekht/account-master/src/internal/auth"
+	"github.com/lekht/account-master/src/internal/events"
+	"github.com/lekht/account-master/src/internal/metrics"
+	"github.com/lekht/account-master/src/internal/token"
+	"github.com/lekht/account-master/src/internal/webhook"
+)
+
+type Option func(*Router)
+
+// Tokens sets issuer of impersonation tokens.
+func Tokens(issuer *token.Issuer) Option {
+	return func(r *Router) {
+		r.tokens = issuer
+	}
+}
+
+// Audit sets sink of account mutation records.
+func Audit(sink audit.Sink) Option {
+	return func(r *Router) {
+		r.audit = sink
+	}
+}
+
+// Webhooks enables webhook management routes backed by store.
+func Webhooks(store webhook.Store) Option {
+	return func(r *Router) {
+		r.webhooks = store
+	}
+}
+
+// Idempotency enables Idempotency-Key handling of mutating routes.
+// Responses are kept in store for ttl, non-positive ttl keeps default.
+func Idempotency(store IdempotencyStore, ttl time.Duration) Option {
+	return func(r *Router) {
+		r.idempotency = store
+
+		if ttl > 0 {
+			r.idempotencyTTL = ttl
+		}
+	}
+}
+
+// ExportHashes grants include_hashes permission to admins with given ids,
+// so they can export password hashes for migrations. Grant is keyed on id,
+// not username, so renamed or recreated account does not inherit it.
+func ExportHashes(ids ...uuid.UUID) Option {
+	return func(r *Router) {
+		r.exportHashes = make(map[uuid.UUID]bool, len(ids))
+		for _, id := range ids {
+			r.exportHashes[id] = true
+		}
+	}
+}
+
+// Logger sets logger of requests and handler errors. Every line
+// carries request id, route and authenticated user.
+func Logger(l *slog.Logger) Option {
+	return func(r *Router) {
+		r.logger = l
+	}
+}
+
+// CertRules enables authentication with verified mTLS client certificates
+// mapped to accounts by rules. Authorization header takes precedence.
+func CertRules(rules ...auth.CertRule) Option {
+	return func(r *Router) {
+		r.certRules = rules
+	}
+}
+
+// Metrics enables recording of requests and authentication attempts.
+// Handler of m is not mounted, see metrics.Metrics.Handler.
+func Metrics(m *metrics.Metrics) Option {
+	return func(r *Router) {
+		r.metrics = m
+	}
+}
+
+// HealthCheck adds dependency checked by /readyz under name.
+func HealthCheck(name string, p Pinger) Option {
+	return func(r *Router) {
+		if r.healthChecks == nil {
+			r.healthChecks = make(map[string]Pinger)
+		}
+		r.healthChecks[name] = p
+	}
+}
+
+// Events enables user events stream fed by broker.
+func Events(broker *events.Broker) Option {
+	return func(r *Router) {
+		r.events = broker
+	}
+}
+
+// WriteTimeout of HTTP server. Streaming handlers extend write deadline
+// by this value before every write.
+func WriteTimeout(timeout time.Duration) Option {
+	return func(r *Router) {
+		r.writeTimeout = timeout
+	}
+}
+
+// GraphQLLimits sets max depth and complexity of GraphQL queries.
+// Non-positive values keep defaults.
+func GraphQLLimits(maxDepth, maxComplexity int) Option {
+	return func(r *Router) {
+		if maxDepth > 0 {
+			r.gqlMaxDepth = maxDepth
+		}
+
+		if maxComplexity > 0 {
+			r.gqlMaxComplexity = maxComplexity
+		}
+	}
+}
+
+// Deprecate sets deprecation and sunset dates reported by unversioned routes.
+func Deprecate(d Deprecation) Option {
+	return func(r *Router) {
+		r.deprecation = d
+	}
+}
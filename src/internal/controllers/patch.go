@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/internal/patch"
+)
+
+// maxPatchSize limits patch document.
+const maxPatchSize = 1 << 20
+
+// patchedAccount is user document after patch. Password is write-only,
+// so it is absent from document unless patch adds it.
+type patchedAccount struct {
+	Id       uuid.UUID `json:"id"`
+	Email    *string   `json:"email"`
+	Username *string   `json:"username"`
+	Password *string   `json:"password"`
+	Admin    *bool     `json:"admin"`
+}
+
+// patchUserById()
+//
+//	@Summary		Patch User
+//	@Description	Patch user by ID with JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902).
+//	@Description	Document is {id, email, username, admin}, password may be added to change it
+//	@Security		BasicAuth
+//	@Accept			application/merge-patch+json
+//	@Accept			application/json-patch+json
+//	@Produce		json
+//	@Param			id		path	string	true	"User ID"
+//	@Param			patch	body	object	true	"patch document"
+//	@Param			Idempotency-Key	header	string	false	"Key to safely retry request"
+//	@Success		200		{object}	AccountResponse
+//	@Failure		400		{object}	Problem
+//	@Failure		404		{object}	Problem
+//	@Failure		409		{object}	Problem
+//	@Failure		413		{object}	Problem
+//	@Failure		415		{object}	Problem
+//	@Failure		422		{object}	Problem
+//	@Router			/user/{id} [patch]
+func (r *Router) patchUserById(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		abortWithError(c, errInvalidUserID)
+		return
+	}
+
+	contentType := c.ContentType()
+	if contentType != patch.MergePatchType && contentType != patch.JSONPatchType {
+		c.Header("Accept-Patch", patch.MergePatchType+", "+patch.JSONPatchType)
+		abortWithError(c, newProblem(http.StatusUnsupportedMediaType, CodeUnsupportedMedia,
+			"content type must be "+patch.MergePatchType+" or "+patch.JSONPatchType))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxPatchSize))
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		abortWithError(c, newProblem(http.StatusRequestEntityTooLarge, CodeInvalidParameter,
+			fmt.Sprintf("patch is larger than %d bytes", maxPatchSize)))
+		return
+	} else if err != nil {
+		abortWithError(c, errInvalidJSON)
+		return
+	}
+
+	before, err := r.repo.UserByID(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	current, err := profileToResponse(&before)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	doc, err := json.Marshal(current)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	patched, err := patch.Apply(contentType, doc, body)
+	switch {
+	case errors.Is(err, patch.ErrTestFailed):
+		abortWithError(c, newProblem(http.StatusConflict, CodePatchTestFailed, err.Error()))
+		return
+	case errors.Is(err, patch.ErrInvalidPatch), errors.Is(err, patch.ErrPath):
+		abortWithError(c, newProblem(http.StatusBadRequest, CodeInvalidPatch, err.Error()))
+		return
+	case err != nil:
+		abortWithError(c, err)
+		return
+	}
+
+	u, setPassword, err := patchedToProfile(patched, id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	if setPassword && isImpersonated(c) {
+		abortWithError(c, errPasswordImpersonated)
+		return
+	}
+
+	after, err := r.replaceUser(c, before, u, setPassword)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	resp, err := profileToResponse(&after)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// patchedToProfile decodes patched document. Removed email is cleared,
+// other members are required.
+func patchedToProfile(doc []byte, id uuid.UUID) (model.Profile, bool, error) {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.DisallowUnknownFields()
+
+	var a patchedAccount
+	if err := dec.Decode(&a); err != nil {
+		return model.Profile{}, false, newProblem(http.StatusUnprocessableEntity, CodeValidationFailed, "patched document is not valid user: "+err.Error())
+	}
+
+	if a.Id != id {
+		return model.Profile{}, false, newProblem(http.StatusUnprocessableEntity, CodeValidationFailed, "id can not be changed")
+	}
+
+	if a.Username == nil || a.Admin == nil {
+		return model.Profile{}, false, newProblem(http.StatusUnprocessableEntity, CodeValidationFailed, "username and admin are required")
+	}
+
+	p := model.Profile{Id: id, Username: *a.Username, Admin: *a.Admin}
+	if a.Email != nil {
+		p.Email = *a.Email
+	}
+
+	if a.Password != nil {
+		p.Password = *a.Password
+	}
+
+	return p, a.Password != nil, nil
+}
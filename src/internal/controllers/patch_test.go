@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lekht/account-master/src/internal/hash"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage/mock"
+)
+
+func TestRouter_PatchUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		status      int
+		code        string
+		want        model.Profile
+	}{
+		{
+			name:        "merge patch clears email",
+			method:      http.MethodPatch,
+			contentType: "application/merge-patch+json",
+			body:        `{"email":null,"admin":true}`,
+			status:      http.StatusOK,
+			want:        model.Profile{Username: "user", Admin: true},
+		},
+		{
+			name:        "json patch with test",
+			method:      http.MethodPatch,
+			contentType: "application/json-patch+json",
+			body:        `[{"op":"test","path":"/username","value":"user"},{"op":"replace","path":"/email","value":""}]`,
+			status:      http.StatusOK,
+			want:        model.Profile{Username: "user"},
+		},
+		{
+			name:        "json patch test fails",
+			method:      http.MethodPatch,
+			contentType: "application/json-patch+json",
+			body:        `[{"op":"test","path":"/username","value":"other"},{"op":"replace","path":"/email","value":""}]`,
+			status:      http.StatusConflict,
+			code:        CodePatchTestFailed,
+		},
+		{
+			name:        "json patch under null member",
+			method:      http.MethodPatch,
+			contentType: "application/json-patch+json",
+			body:        `[{"op":"replace","path":"/email","value":null},{"op":"add","path":"/email/domain","value":"example.org"}]`,
+			status:      http.StatusBadRequest,
+			code:        CodeInvalidPatch,
+		},
+		{
+			name:        "too large",
+			method:      http.MethodPatch,
+			contentType: "application/merge-patch+json",
+			body:        "{" + strings.Repeat(" ", maxPatchSize) + "}",
+			status:      http.StatusRequestEntityTooLarge,
+			code:        CodeInvalidParameter,
+		},
+		{
+			name:        "removed username",
+			method:      http.MethodPatch,
+			contentType: "application/merge-patch+json",
+			body:        `{"username":null}`,
+			status:      http.StatusUnprocessableEntity,
+			code:        CodeValidationFailed,
+		},
+		{
+			name:        "invalid email",
+			method:      http.MethodPatch,
+			contentType: "application/merge-patch+json",
+			body:        `{"email":"nope"}`,
+			status:      http.StatusUnprocessableEntity,
+			code:        CodeValidationFailed,
+		},
+		{
+			name:        "unknown member",
+			method:      http.MethodPatch,
+			contentType: "application/merge-patch+json",
+			body:        `{"role":"root"}`,
+			status:      http.StatusUnprocessableEntity,
+			code:        CodeValidationFailed,
+		},
+		{
+			name:        "plain json",
+			method:      http.MethodPatch,
+			contentType: "application/json",
+			body:        `{"email":null}`,
+			status:      http.StatusUnsupportedMediaType,
+			code:        CodeUnsupportedMedia,
+		},
+		{
+			name:        "put replaces profile",
+			method:      http.MethodPut,
+			contentType: "application/json",
+			body:        `{"email":"","username":"renamed","admin":false}`,
+			status:      http.StatusOK,
+			want:        model.Profile{Username: "renamed"},
+		},
+		{
+			name:        "put requires all fields",
+			method:      http.MethodPut,
+			contentType: "application/json",
+			body:        `{"username":"renamed"}`,
+			status:      http.StatusUnprocessableEntity,
+			code:        CodeValidationFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mock.New()
+			pwd, _ := hash.HashPassword(context.Background(), "admin")
+			_, _ = m.CreateUser(context.Background(), model.Profile{Username: "admin", Password: pwd, Admin: true})
+			user, _ := m.CreateUser(context.Background(), model.Profile{Username: "user", Email: "user@example.com", Password: "hash"})
+
+			req := httptest.NewRequest(tt.method, "/v1/user/"+user.Id.String(), strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req.SetBasicAuth("admin", "admin")
+			w := httptest.NewRecorder()
+
+			New(m).Router().ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			if tt.code != "" {
+				var p Problem
+				_ = json.Unmarshal(w.Body.Bytes(), &p)
+				if p.Code != tt.code {
+					t.Errorf("code = %q, want %q", p.Code, tt.code)
+				}
+				return
+			}
+
+			got, _ := m.UserByID(context.Background(), user.Id)
+			tt.want.Id, tt.want.Password = user.Id, user.Password
+			if got != tt.want {
+				t.Errorf("user = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
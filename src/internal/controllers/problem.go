@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lekht/account-master/src/pkg/storage"
+)
+
+const problemContentType = "application/problem+json"
+
+// Stable machine-readable error codes. Clients must rely on them
+// instead of detail text.
+const (
+	CodeInvalidJSON           = "invalid_json"
+	CodeInvalidParameter      = "invalid_parameter"
+	CodeInvalidUserID         = "invalid_user_id"
+	CodeInvalidWebhookID      = "invalid_webhook_id"
+	CodeInvalidPatch          = "invalid_patch"
+	CodePatchTestFailed       = "patch_test_failed"
+	CodeUnsupportedMedia      = "unsupported_media_type"
+	CodeValidationFailed      = "validation_failed"
+	CodeBatchRolledBack       = "batch_rolled_back"
+	CodeTxNotSupported        = "transactions_not_supported"
+	CodeUserNotFound          = "user_not_found"
+	CodeUsersNotFound         = "users_not_found"
+	CodeWebhookNotFound       = "webhook_not_found"
+	CodeUserExists            = "user_exists"
+	CodeIdempotencyMismatch   = "idempotency_key_reused"
+	CodeIdempotencyInProgress = "idempotency_key_in_progress"
+	CodeAuthRequired          = "authentication_required"
+	CodeInvalidCredentials    = "invalid_credentials"
+	CodeInvalidToken          = "invalid_token"
+	CodeInvalidCertificate    = "invalid_certificate"
+	CodePermissionDenied      = "permission_denied"
+	CodeImpersonationDenied   = "impersonation_denied"
+	CodeRequestCanceled       = "request_canceled"
+	CodeInternal              = "internal_error"
+)
+
+// Problem is error response of RFC 7807 with extension member "code".
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+}
+
+func (p *Problem) Error() string {
+	return p.Code + ": " + p.Detail
+}
+
+func newProblem(status int, code, detail string) *Problem {
+	return &Problem{
+		Type:   "/problems/" + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+var (
+	errInvalidJSON          = newProblem(http.StatusBadRequest, CodeInvalidJSON, "request body is not valid JSON")
+	errInvalidUserID        = newProblem(http.StatusBadRequest, CodeInvalidUserID, "invalid user id")
+	errUserNotFound         = newProblem(http.StatusNotFound, CodeUserNotFound, "user not found")
+	errUserExists           = newProblem(http.StatusConflict, CodeUserExists, "user already exists")
+	errForbidden            = newProblem(http.StatusForbidden, CodePermissionDenied, "permission denied")
+	errPasswordImpersonated = newProblem(http.StatusForbidden, CodeImpersonationDenied, "password can not be changed while impersonating")
+	errRequestCanceled      = newProblem(http.StatusServiceUnavailable, CodeRequestCanceled, "request was canceled or timed out")
+	errInternal             = newProblem(http.StatusInternalServerError, CodeInternal, "internal server error")
+	errWebhookNotFound      = newProblem(http.StatusNotFound, CodeWebhookNotFound, "webhook not found")
+)
+
+// problemFromError maps repository errors to problems. It is the only place
+// where storage errors are translated to HTTP statuses.
+func problemFromError(err error) *Problem {
+	var p *Problem
+
+	switch {
+	case errors.As(err, &p):
+		return p
+	case errors.Is(err, storage.ErrNoUserID), errors.Is(err, storage.ErrNoUsername):
+		return errUserNotFound
+	case errors.Is(err, storage.ErrUserExists):
+		return errUserExists
+	case errors.Is(err, storage.ErrNoWebhookID):
+		return errWebhookNotFound
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return errRequestCanceled
+	default:
+		return errInternal
+	}
+}
+
+// abortWithError renders err as problem+json and aborts request.
+func abortWithError(c *gin.Context, err error) {
+	p := *problemFromError(err)
+	p.Instance = c.Request.URL.Path
+
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(p.Status, p)
+}
@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lekht/account-master/src/internal/hash"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage/mock"
+)
+
+func TestRouter_Problems(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	m := mock.New()
+	for _, u := range []model.Profile{
+		{Username: "admin", Password: "admin", Admin: true},
+		{Username: "user", Password: "user"},
+	} {
+		pwd, _ := hash.HashPassword(context.Background(), u.Password)
+		u.Password = pwd
+		if _, err := m.CreateUser(context.Background(), u); err != nil {
+			t.Fatalf("CreateUser() error = %v", err)
+		}
+	}
+
+	r := New(m)
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		user     string
+		password string
+		status   int
+		code     string
+	}{
+		{name: "no credentials", method: http.MethodGet, path: "/v1/user", status: http.StatusUnauthorized, code: CodeAuthRequired},
+		{name: "wrong password", method: http.MethodGet, path: "/v1/user", user: "admin", password: "x", status: http.StatusUnauthorized, code: CodeInvalidCredentials},
+		{name: "not admin", method: http.MethodDelete, path: "/v1/user/" + "00000000-0000-0000-0000-000000000000", user: "user", password: "user", status: http.StatusForbidden, code: CodePermissionDenied},
+		{name: "invalid id", method: http.MethodGet, path: "/v1/user/42", user: "admin", password: "admin", status: http.StatusBadRequest, code: CodeInvalidUserID},
+		{name: "not found", method: http.MethodGet, path: "/v1/user/00000000-0000-0000-0000-000000000000", user: "admin", password: "admin", status: http.StatusNotFound, code: CodeUserNotFound},
+		{name: "invalid json", method: http.MethodPost, path: "/v1/user", body: "{", user: "admin", password: "admin", status: http.StatusBadRequest, code: CodeInvalidJSON},
+		{name: "exists", method: http.MethodPost, path: "/v1/user", body: `{"email":"a@b.c","username":"user","password":"p","admin":false}`, user: "admin", password: "admin", status: http.StatusConflict, code: CodeUserExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.user != "" {
+				req.SetBasicAuth(tt.user, tt.password)
+			}
+			w := httptest.NewRecorder()
+
+			r.Router().ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+
+			if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, problemContentType) {
+				t.Errorf("Content-Type = %q, want %q", got, problemContentType)
+			}
+
+			var p Problem
+			if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			if p.Code != tt.code || p.Status != tt.status || p.Instance != tt.path {
+				t.Errorf("problem = %+v, want code %q status %d instance %q", p, tt.code, tt.status, tt.path)
+			}
+		})
+	}
+}
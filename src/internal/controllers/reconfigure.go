@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Settings of Router that can be changed while serving, see Reconfigure.
+// Zero values mean the same as for corresponding options.
+type Settings struct {
+	IdempotencyTTL       time.Duration
+	ExportHashes         []uuid.UUID
+	Deprecation          Deprecation
+	GraphQLMaxDepth      int
+	GraphQLMaxComplexity int
+}
+
+// Reconfigure replaces settings of serving router, e.g. on config reload.
+// Requests started before keep settings they have read.
+func (r *Router) Reconfigure(s Settings) {
+	opts := []Option{
+		ExportHashes(s.ExportHashes...),
+		GraphQLLimits(s.GraphQLMaxDepth, s.GraphQLMaxComplexity),
+		Deprecate(s.Deprecation),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.idempotencyTTL = defaultIdempotency
+	if s.IdempotencyTTL > 0 {
+		r.idempotencyTTL = s.IdempotencyTTL
+	}
+
+	r.gqlMaxDepth, r.gqlMaxComplexity = defaultMaxDepth, defaultMaxComplexity
+	for _, opt := range opts {
+		opt(r)
+	}
+}
+
+func (r *Router) currentIdempotencyTTL() time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.idempotencyTTL
+}
+
+func (r *Router) canExportHashes(id uuid.UUID) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.exportHashes[id]
+}
+
+func (r *Router) currentDeprecation() Deprecation {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.deprecation
+}
+
+func (r *Router) graphQLLimits() (maxDepth, maxComplexity int) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.gqlMaxDepth, r.gqlMaxComplexity
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lekht/account-master/src/internal/hash"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage/mock"
+)
+
+func TestRouter_Reconfigure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	m := mock.New()
+	pwd, _ := hash.HashPassword(context.Background(), "admin")
+	if _, err := m.CreateUser(context.Background(), model.Profile{Username: "admin", Password: pwd, Admin: true}); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	r := New(m)
+	since := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		settings    Settings
+		deprecation string
+		depth       int
+	}{
+		{
+			name:        "deprecation date and limits",
+			settings:    Settings{Deprecation: Deprecation{Since: since}, GraphQLMaxDepth: 3},
+			deprecation: "@" + strconv.FormatInt(since.Unix(), 10),
+			depth:       3,
+		},
+		{name: "reset to defaults", settings: Settings{}, deprecation: "true", depth: defaultMaxDepth},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r.Reconfigure(tt.settings)
+
+			req := httptest.NewRequest(http.MethodGet, "/user", nil)
+			req.SetBasicAuth("admin", "admin")
+			w := httptest.NewRecorder()
+
+			r.Router().ServeHTTP(w, req)
+
+			if got := w.Header().Get("Deprecation"); got != tt.deprecation {
+				t.Errorf("Deprecation = %q, want %q", got, tt.deprecation)
+			}
+
+			if depth, _ := r.graphQLLimits(); depth != tt.depth {
+				t.Errorf("max depth = %d, want %d", depth, tt.depth)
+			}
+		})
+	}
+}
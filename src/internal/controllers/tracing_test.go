@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lekht/account-master/src/internal/hash"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/internal/tracing"
+	"github.com/lekht/account-master/src/pkg/storage/mock"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRouter_Tracing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := tracing.NewProvider(sdktrace.WithSyncer(exporter), 1)
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	tracing.Install(tp)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+		_ = tp.Shutdown(context.Background())
+	})
+
+	m := mock.New()
+	pwd, _ := hash.HashPassword(context.Background(), "admin")
+	admin, _ := m.CreateUser(context.Background(), model.Profile{Username: "admin", Password: pwd, Admin: true})
+
+	r := New(tracing.Repository(m))
+	exporter.Reset()
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/v1/user/"+admin.Id.String(), nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	req.SetBasicAuth("admin", "admin")
+	w := httptest.NewRecorder()
+
+	r.Router().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+
+	spans := make(map[string]tracetest.SpanStub)
+	for _, s := range exporter.GetSpans() {
+		if s.SpanContext.TraceID().String() != traceID {
+			t.Errorf("span %q has trace %s, want %s", s.Name, s.SpanContext.TraceID(), traceID)
+		}
+		spans[s.Name] = s
+	}
+
+	// span -> parent span, empty parent is remote caller
+	tests := []struct {
+		name   string
+		parent string
+	}{
+		{name: "GET /v1/user/:id"},
+		{name: "auth.Authorization", parent: "GET /v1/user/:id"},
+		{name: "repository.UserByName", parent: "auth.Authorization"},
+		{name: "hash.CheckPassword", parent: "auth.Authorization"},
+		{name: "repository.UserByID", parent: "GET /v1/user/:id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, ok := spans[tt.name]
+			if !ok {
+				t.Fatalf("span not recorded, got %v", exporter.GetSpans().Snapshots())
+			}
+
+			want := trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7}
+			if tt.parent != "" {
+				want = spans[tt.parent].SpanContext.SpanID()
+			}
+
+			if s.Parent.SpanID() != want {
+				t.Errorf("parent = %s, want %s (%s)", s.Parent.SpanID(), want, tt.parent)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIVersion describes routes of single API version. Routes are registered
+// by Router method, so every version shares repository and middleware.
+// To add new version, write its register method, reusing or replacing
+// handlers of previous one, and pass it to mountVersions.
+type APIVersion struct {
+	Prefix   string
+	Register func(r *Router, g *gin.RouterGroup)
+}
+
+var v1 = APIVersion{Prefix: "/v1", Register: (*Router).registerV1}
+
+// Deprecation of unversioned routes. Zero times are not reported.
+type Deprecation struct {
+	Since  time.Time
+	Sunset time.Time
+}
+
+// mountVersions mounts every version under its prefix. Routes of the first
+// version are also kept at root as deprecated aliases for old clients.
+func (r *Router) mountVersions(versions ...APIVersion) {
+	for _, v := range versions {
+		v.Register(r, r.router.Group(v.Prefix))
+	}
+
+	if len(versions) > 0 {
+		legacy := versions[0]
+		legacy.Register(r, r.router.Group("", deprecationMiddleware(legacy.Prefix, r.currentDeprecation)))
+	}
+}
+
+func (r *Router) registerV1(g *gin.RouterGroup) {
+	authenticated := g.Group("/user", r.basicAuthMiddleware())
+	{
+		authenticated.GET("", r.getUsers)
+		authenticated.GET("/:id", r.getUserById)
+		authenticated.POST("", isAdminMiddleware(), r.idempotencyMiddleware(), r.createUser)
+		authenticated.GET("/export", isAdminMiddleware(), r.exportUsers)
+		authenticated.POST("/batch", isAdminMiddleware(), r.idempotencyMiddleware(), r.batchUsers)
+		authenticated.POST("/import", isAdminMiddleware(), r.idempotencyMiddleware(), r.importUsers)
+		authenticated.PUT("/:id", isAdminMiddleware(), r.idempotencyMiddleware(), r.updateUserById)
+		authenticated.PATCH("/:id", isAdminMiddleware(), r.idempotencyMiddleware(), r.patchUserById)
+		authenticated.DELETE("/:id", isAdminMiddleware(), r.idempotencyMiddleware(), r.deleteUserById)
+		authenticated.POST("/:id/impersonate", isAdminMiddleware(), r.impersonateUser)
+
+		if r.events != nil {
+			authenticated.GET("/events", isAdminMiddleware(), r.streamEvents)
+		}
+	}
+
+	g.GET("/audit", r.basicAuthMiddleware(), isAdminMiddleware(), r.getAudit)
+	g.POST("/graphql", r.basicAuthMiddleware(), r.graphQL)
+
+	if r.webhooks != nil {
+		hooks := g.Group("/webhook", r.basicAuthMiddleware(), isAdminMiddleware())
+		{
+			hooks.GET("", r.getWebhooks)
+			hooks.POST("", r.idempotencyMiddleware(), r.createWebhook)
+			hooks.DELETE("/:id", r.idempotencyMiddleware(), r.deleteWebhook)
+			hooks.GET("/:id/deliveries", r.getWebhookDeliveries)
+		}
+	}
+}
+
+// deprecationMiddleware marks response of unversioned route as deprecated
+// (RFC 9745, RFC 8594) and links to the same route of successor version.
+// Dates are read per request, so they follow Reconfigure.
+func deprecationMiddleware(successor string, deprecation func() Deprecation) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		d := deprecation()
+		if d.Since.IsZero() {
+			c.Header("Deprecation", "true")
+		} else {
+			c.Header("Deprecation", "@"+strconv.FormatInt(d.Since.Unix(), 10))
+		}
+
+		if !d.Sunset.IsZero() {
+			c.Header("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+		}
+
+		c.Header("Link", "<"+successor+c.Request.URL.Path+`>; rel="successor-version"`)
+
+		c.Next()
+	}
+}
@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lekht/account-master/src/internal/hash"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage/mock"
+)
+
+func TestRouter_Versions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	m := mock.New()
+	pwd, _ := hash.HashPassword(context.Background(), "admin")
+	if _, err := m.CreateUser(context.Background(), model.Profile{Username: "admin", Password: pwd, Admin: true}); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	sunset := time.Date(2027, 4, 19, 0, 0, 0, 0, time.UTC)
+	r := New(m, Deprecate(Deprecation{Since: sunset.AddDate(0, -6, 0), Sunset: sunset}))
+
+	tests := []struct {
+		name       string
+		path       string
+		deprecated bool
+	}{
+		{name: "versioned", path: "/v1/user"},
+		{name: "legacy alias", path: "/user", deprecated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.SetBasicAuth("admin", "admin")
+			w := httptest.NewRecorder()
+
+			r.Router().ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET %s status = %d, want %d", tt.path, w.Code, http.StatusOK)
+			}
+
+			if got := w.Header().Get("Deprecation") != ""; got != tt.deprecated {
+				t.Errorf("GET %s deprecated = %v, want %v", tt.path, got, tt.deprecated)
+			}
+
+			if !tt.deprecated {
+				return
+			}
+
+			if got := w.Header().Get("Sunset"); got != "Mon, 19 Apr 2027 00:00:00 GMT" {
+				t.Errorf("Sunset = %q", got)
+			}
+
+			if got := w.Header().Get("Link"); got != `</v1/user>; rel="successor-version"` {
+				t.Errorf("Link = %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lekht/account-master/src/internal/model"
+)
+
+// createWebhook
+//
+//	@Summary		Create Webhook
+//	@Description	Register endpoint notified about account events. Secret is generated if omitted and returned only once
+//	@Security		BasicAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			webhook	body		WebhookRequest	true	"URL, Events, Secret"
+//	@Param			Idempotency-Key	header	string	false	"Key to safely retry request"
+//	@Success		201		{object}	WebhookResponse
+//	@Failure		400	{object}	Problem
+//	@Failure		403	{object}	Problem
+//	@Router			/webhook [post]
+func (r *Router) createWebhook(c *gin.Context) {
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidJSON)
+		return
+	}
+
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		abortWithError(c, newProblem(http.StatusBadRequest, CodeInvalidParameter, "invalid url"))
+		return
+	}
+
+	for _, e := range req.Events {
+		if e != model.EventUserCreated && e != model.EventUserUpdated && e != model.EventUserDeleted {
+			abortWithError(c, newProblem(http.StatusBadRequest, CodeInvalidParameter, "unknown event "+e))
+			return
+		}
+	}
+
+	if req.Secret == "" {
+		buf := make([]byte, 32)
+		if _, err = rand.Read(buf); err != nil {
+			abortWithError(c, fmt.Errorf("failed to generate webhook secret: %w", err))
+			return
+		}
+		req.Secret = hex.EncodeToString(buf)
+	}
+
+	w, err := r.webhooks.CreateWebhook(model.Webhook{
+		URL:    u.String(),
+		Events: req.Events,
+		Secret: req.Secret,
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	resp := webhookToResponse(w)
+	resp.Secret = w.Secret
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+// getWebhooks
+//
+//	@Summary		Get Webhooks
+//	@Description	Get registered webhooks
+//	@Security		BasicAuth
+//	@Produce		json
+//	@Success		200
+//	@Failure		403	{object}	Problem
+//	@Router			/webhook [get]
+func (r *Router) getWebhooks(c *gin.Context) {
+	hooks, err := r.webhooks.Webhooks()
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	responses := make([]WebhookResponse, 0, len(hooks))
+	for _, w := range hooks {
+		responses = append(responses, webhookToResponse(w))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": responses})
+}
+
+// deleteWebhook
+//
+//	@Summary		Delete Webhook
+//	@Description	Delete webhook by ID. Pending deliveries are canceled
+//	@Security		BasicAuth
+//	@Param			id	path	string	true	"Webhook ID"
+//	@Param			Idempotency-Key	header	string	false	"Key to safely retry request"
+//	@Success		200
+//	@Failure		400	{object}	Problem
+//	@Failure		403	{object}	Problem
+//	@Failure		404	{object}	Problem
+//	@Router			/webhook/{id} [delete]
+func (r *Router) deleteWebhook(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		abortWithError(c, newProblem(http.StatusBadRequest, CodeInvalidWebhookID, "invalid webhook id"))
+		return
+	}
+
+	if err = r.webhooks.DeleteWebhook(id); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// getWebhookDeliveries
+//
+//	@Summary		Get Webhook Deliveries
+//	@Description	Get delivery history of webhook, newest first
+//	@Security		BasicAuth
+//	@Produce		json
+//	@Param			id	path	string	true	"Webhook ID"
+//	@Success		200
+//	@Failure		400	{object}	Problem
+//	@Failure		403	{object}	Problem
+//	@Failure		404	{object}	Problem
+//	@Router			/webhook/{id}/deliveries [get]
+func (r *Router) getWebhookDeliveries(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		abortWithError(c, newProblem(http.StatusBadRequest, CodeInvalidWebhookID, "invalid webhook id"))
+		return
+	}
+
+	deliveries, err := r.webhooks.Deliveries(id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	if len(deliveries) == 0 {
+		if _, err = r.webhooks.WebhookByID(id); err != nil {
+			abortWithError(c, err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": deliveries})
+}
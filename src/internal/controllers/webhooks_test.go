@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lekht/account-master/src/internal/hash"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage/mock"
+)
+
+func TestRouter_Webhooks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	m := mock.New()
+	for _, p := range []model.Profile{
+		{Username: "admin", Admin: true},
+		{Username: "user"},
+	} {
+		p.Password, _ = hash.HashPassword(context.Background(), p.Username)
+		if _, err := m.CreateUser(context.Background(), p); err != nil {
+			t.Fatalf("CreateUser() error = %v", err)
+		}
+	}
+
+	r := New(m, Webhooks(m))
+
+	// id of webhook created by first step
+	var id uuid.UUID
+
+	tests := []struct {
+		name   string
+		user   string
+		method string
+		path   func() string
+		body   string
+		status int
+		check  func(t *testing.T, body []byte)
+	}{
+		{
+			name: "create with generated secret", user: "admin", method: http.MethodPost,
+			path:   func() string { return "/v1/webhook" },
+			body:   `{"url":"https://example.org/hook","events":["user.created"]}`,
+			status: http.StatusCreated,
+			check: func(t *testing.T, body []byte) {
+				var resp WebhookResponse
+				if err := json.Unmarshal(body, &resp); err != nil {
+					t.Fatalf("Unmarshal() error = %v", err)
+				}
+				if len(resp.Secret) != 64 || resp.URL != "https://example.org/hook" {
+					t.Errorf("webhook = %+v", resp)
+				}
+				id = resp.Id
+			},
+		},
+		{
+			name: "invalid url", user: "admin", method: http.MethodPost,
+			path:   func() string { return "/v1/webhook" },
+			body:   `{"url":"ftp://example.org"}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "unknown event", user: "admin", method: http.MethodPost,
+			path:   func() string { return "/v1/webhook" },
+			body:   `{"url":"https://example.org","events":["user.renamed"]}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "create by non-admin", user: "user", method: http.MethodPost,
+			path:   func() string { return "/v1/webhook" },
+			body:   `{"url":"https://example.org/hook"}`,
+			status: http.StatusForbidden,
+		},
+		{
+			name: "list hides secret", user: "admin", method: http.MethodGet,
+			path:   func() string { return "/v1/webhook" },
+			status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				var resp struct{ Data []WebhookResponse }
+				if err := json.Unmarshal(body, &resp); err != nil {
+					t.Fatalf("Unmarshal() error = %v", err)
+				}
+				if len(resp.Data) != 1 || resp.Data[0].Id != id || resp.Data[0].Secret != "" {
+					t.Errorf("webhooks = %+v", resp.Data)
+				}
+			},
+		},
+		{
+			name: "list by non-admin", user: "user", method: http.MethodGet,
+			path:   func() string { return "/v1/webhook" },
+			status: http.StatusForbidden,
+		},
+		{
+			name: "deliveries", user: "admin", method: http.MethodGet,
+			path:   func() string { return "/v1/webhook/" + id.String() + "/deliveries" },
+			status: http.StatusOK,
+		},
+		{
+			name: "deliveries of unknown webhook", user: "admin", method: http.MethodGet,
+			path:   func() string { return "/v1/webhook/" + uuid.NewString() + "/deliveries" },
+			status: http.StatusNotFound,
+		},
+		{
+			name: "deliveries with invalid id", user: "admin", method: http.MethodGet,
+			path:   func() string { return "/v1/webhook/42/deliveries" },
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "delete by non-admin", user: "user", method: http.MethodDelete,
+			path:   func() string { return "/v1/webhook/" + id.String() },
+			status: http.StatusForbidden,
+		},
+		{
+			name: "delete", user: "admin", method: http.MethodDelete,
+			path:   func() string { return "/v1/webhook/" + id.String() },
+			status: http.StatusOK,
+		},
+		{
+			name: "delete again", user: "admin", method: http.MethodDelete,
+			path:   func() string { return "/v1/webhook/" + id.String() },
+			status: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path(), strings.NewReader(tt.body))
+			req.SetBasicAuth(tt.user, tt.user)
+			w := httptest.NewRecorder()
+
+			r.Router().ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			if tt.check != nil {
+				tt.check(t, w.Body.Bytes())
+			}
+		})
+	}
+}
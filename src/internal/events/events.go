@@ -0,0 +1,125 @@
+package events
+
+import (
+	"sync"
+
+	"github.com/lekht/account-master/src/internal/model"
+)
+
+const (
+	defaultBufferSize    = 1024
+	subscriberBufferSize = 64
+)
+
+// Entry is event with sequence number used as SSE event id.
+type Entry struct {
+	Seq   uint64
+	Event model.Event
+}
+
+// Broker fans out published events to subscribers and keeps last events
+// in bounded ring buffer, so subscribers can resume after reconnect.
+type Broker struct {
+	buf  []Entry
+	head int // index of oldest entry
+	size int
+	seq  uint64
+	subs map[chan Entry]struct{}
+	done bool
+
+	mu sync.Mutex
+}
+
+func New(size int) *Broker {
+	if size <= 0 {
+		size = defaultBufferSize
+	}
+
+	return &Broker{
+		buf:  make([]Entry, size),
+		subs: make(map[chan Entry]struct{}),
+	}
+}
+
+// Publish never blocks. Subscriber that can not keep up is dropped,
+// its channel is closed and it is expected to resume by sequence number.
+func (b *Broker) Publish(e model.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	entry := Entry{Seq: b.seq, Event: e}
+
+	if b.size < len(b.buf) {
+		b.buf[(b.head+b.size)%len(b.buf)] = entry
+		b.size++
+	} else {
+		b.buf[b.head] = entry
+		b.head = (b.head + 1) % len(b.buf)
+	}
+
+	for ch := range b.subs {
+		select {
+		case ch <- entry:
+		default:
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe returns buffered entries with sequence number greater than after
+// and channel of new entries. Cancel must be called to release subscription.
+// Reset is reported and all buffered entries are returned when entries
+// after it are lost: after is ahead of sequence, which starts from zero on
+// every process start, or entries following it were evicted from buffer.
+// Zero after means start of buffer and is never reset.
+func (b *Broker) Subscribe(after uint64) (backlog []Entry, ch <-chan Entry, cancel func(), reset bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	evicted := after > 0 && b.size > 0 && after+1 < b.buf[b.head].Seq
+	if after > b.seq || evicted {
+		after, reset = 0, true
+	}
+
+	for i := 0; i < b.size; i++ {
+		entry := b.buf[(b.head+i)%len(b.buf)]
+		if entry.Seq > after {
+			backlog = append(backlog, entry)
+		}
+	}
+
+	sub := make(chan Entry, subscriberBufferSize)
+	if b.done {
+		close(sub)
+		return backlog, sub, func() {}, reset
+	}
+	b.subs[sub] = struct{}{}
+
+	cancel = func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if _, ok := b.subs[sub]; ok {
+			delete(b.subs, sub)
+			close(sub)
+		}
+	}
+
+	return backlog, sub, cancel, reset
+}
+
+// Close ends every subscription by closing its channel, so streams
+// finish before server shutdown. Later subscriptions get closed channel
+// right after backlog.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.done = true
+	for ch := range b.subs {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
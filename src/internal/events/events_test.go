@@ -0,0 +1,143 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/lekht/account-master/src/internal/model"
+)
+
+func TestBroker_Subscribe(t *testing.T) {
+	tests := []struct {
+		name      string
+		size      int
+		published int
+		after     uint64
+		want      []uint64
+		reset     bool
+	}{
+		{
+			name:      "all buffered",
+			size:      4,
+			published: 3,
+			want:      []uint64{1, 2, 3},
+		},
+		{
+			name:      "resume after id",
+			size:      4,
+			published: 3,
+			after:     2,
+			want:      []uint64{3},
+		},
+		{
+			name:      "oldest overwritten",
+			size:      2,
+			published: 5,
+			after:     1,
+			want:      []uint64{4, 5},
+			reset:     true,
+		},
+		{
+			name:      "next after id is oldest",
+			size:      2,
+			published: 5,
+			after:     3,
+			want:      []uint64{4, 5},
+		},
+		{
+			name:      "no id with evicted",
+			size:      2,
+			published: 5,
+			want:      []uint64{4, 5},
+		},
+		{
+			name:      "nothing new",
+			size:      2,
+			published: 2,
+			after:     2,
+		},
+		{
+			name:      "id of previous run",
+			size:      4,
+			published: 2,
+			after:     10,
+			want:      []uint64{1, 2},
+			reset:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := New(tt.size)
+			for i := 0; i < tt.published; i++ {
+				b.Publish(model.Event{Type: model.EventUserCreated})
+			}
+
+			backlog, _, cancel, reset := b.Subscribe(tt.after)
+			defer cancel()
+
+			if reset != tt.reset {
+				t.Errorf("Broker.Subscribe() reset = %v, want %v", reset, tt.reset)
+			}
+
+			if len(backlog) != len(tt.want) {
+				t.Fatalf("Broker.Subscribe() got %d entries, want %d", len(backlog), len(tt.want))
+			}
+
+			for i, e := range backlog {
+				if e.Seq != tt.want[i] {
+					t.Errorf("Broker.Subscribe() entry %d seq = %d, want %d", i, e.Seq, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBroker_Publish(t *testing.T) {
+	b := New(subscriberBufferSize * 2)
+
+	_, ch, cancel, _ := b.Subscribe(0)
+	defer cancel()
+
+	b.Publish(model.Event{Type: model.EventUserDeleted})
+	if e := <-ch; e.Seq != 1 || e.Event.Type != model.EventUserDeleted {
+		t.Errorf("received %+v, want seq 1 %s", e, model.EventUserDeleted)
+	}
+
+	// slow subscriber is dropped instead of blocking publisher
+	for i := 0; i <= subscriberBufferSize; i++ {
+		b.Publish(model.Event{})
+	}
+
+	n := 0
+	for range ch {
+		n++
+	}
+
+	if n != subscriberBufferSize {
+		t.Errorf("received %d entries before close, want %d", n, subscriberBufferSize)
+	}
+}
+
+func TestBroker_Close(t *testing.T) {
+	b := New(0)
+	b.Publish(model.Event{})
+
+	_, ch, cancel, _ := b.Subscribe(0)
+	defer cancel()
+
+	b.Close()
+	if _, ok := <-ch; ok {
+		t.Error("subscription open after Close()")
+	}
+
+	// subscribing during shutdown still gets backlog
+	backlog, ch, cancel, _ := b.Subscribe(0)
+	defer cancel()
+
+	if len(backlog) != 1 {
+		t.Errorf("Broker.Subscribe() got %d entries, want 1", len(backlog))
+	}
+	if _, ok := <-ch; ok {
+		t.Error("subscription open after Close()")
+	}
+}
@@ -0,0 +1,271 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lekht/account-master/src/internal/audit"
+	"github.com/lekht/account-master/src/internal/auth"
+	"github.com/lekht/account-master/src/internal/controllers"
+	"github.com/lekht/account-master/src/internal/hash"
+	"github.com/lekht/account-master/src/internal/logger"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/api/accountv1"
+	"github.com/lekht/account-master/src/pkg/storage"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// adminMethods require authenticated user to be admin, same as REST routes
+// behind isAdminMiddleware.
+var adminMethods = map[string]bool{
+	accountv1.AccountService_CreateUser_FullMethodName: true,
+	accountv1.AccountService_UpdateUser_FullMethodName: true,
+	accountv1.AccountService_DeleteUser_FullMethodName: true,
+}
+
+type service struct {
+	accountv1.UnimplementedAccountServiceServer
+
+	repo  controllers.Repository
+	audit audit.Sink
+}
+
+// New creates gRPC server with AccountService registered. Requests are
+// authenticated by authenticator from "authorization" metadata.
+func New(repo controllers.Repository, authenticator *auth.Authenticator, opts ...Option) *grpc.Server {
+	s := &service{
+		repo:  repo,
+		audit: audit.Discard,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(authInterceptor(authenticator)))
+	accountv1.RegisterAccountServiceServer(srv, s)
+
+	return srv
+}
+
+func authInterceptor(authenticator *auth.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var header string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if v := md.Get("authorization"); len(v) > 0 {
+				header = v[0]
+			}
+		}
+
+		id, err := authenticator.Authorization(ctx, header)
+		switch {
+		case errors.Is(err, auth.ErrNoCredentials):
+			return nil, status.Error(codes.Unauthenticated, "authentication required")
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			return nil, status.Error(codes.Unauthenticated, "invalid username or password")
+		case errors.Is(err, auth.ErrInvalidToken):
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		case err != nil:
+			return nil, status.Error(codes.Internal, "internal server error")
+		}
+
+		if adminMethods[info.FullMethod] && !id.Admin {
+			return nil, status.Error(codes.PermissionDenied, "permission denied")
+		}
+
+		resp, err := handler(auth.NewContext(ctx, id), req)
+
+		if id.Impersonated() {
+			logger.FromContext(ctx).Info("impersonated request",
+				"impersonator_id", id.ImpersonatorID, "user_id", id.UserID,
+				"method", info.FullMethod, "code", status.Code(err).String())
+		}
+
+		return resp, err
+	}
+}
+
+func (s *service) ListUsers(ctx context.Context, _ *accountv1.ListUsersRequest) (*accountv1.ListUsersResponse, error) {
+	users, err := s.repo.Users(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal server error")
+	}
+
+	resp := &accountv1.ListUsersResponse{Users: make([]*accountv1.User, 0, len(users))}
+	for _, u := range users {
+		resp.Users = append(resp.Users, profileToUser(u))
+	}
+
+	return resp, nil
+}
+
+func (s *service) GetUser(ctx context.Context, req *accountv1.GetUserRequest) (*accountv1.User, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid user id")
+	}
+
+	u, err := s.repo.UserByID(ctx, id)
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	return profileToUser(u), nil
+}
+
+func (s *service) CreateUser(ctx context.Context, req *accountv1.CreateUserRequest) (*accountv1.User, error) {
+	pwdHash, err := hash.HashPassword(ctx, req.GetPassword())
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal server error")
+	}
+
+	created, err := s.repo.CreateUser(ctx, model.Profile{
+		Email:    req.GetEmail(),
+		Username: req.GetUsername(),
+		Password: pwdHash,
+		Admin:    req.GetAdmin(),
+	})
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	s.recordAudit(ctx, audit.ActionCreate, created.Id, model.Profile{}, created)
+
+	return profileToUser(created), nil
+}
+
+func (s *service) UpdateUser(ctx context.Context, req *accountv1.UpdateUserRequest) (*accountv1.User, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid user id")
+	}
+
+	if caller, _ := auth.FromContext(ctx); req.Password != nil && caller.Impersonated() {
+		return nil, status.Error(codes.PermissionDenied, "password can not be changed while impersonating")
+	}
+
+	before, err := s.repo.UserByID(ctx, id)
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	// storage replaces whole profile, unset fields keep current values
+	p := before
+	if req.Email != nil {
+		p.Email = req.GetEmail()
+	}
+
+	if req.Username != nil {
+		p.Username = req.GetUsername()
+	}
+
+	if req.Password != nil {
+		if p.Password, err = hash.HashPassword(ctx, req.GetPassword()); err != nil {
+			return nil, status.Error(codes.Internal, "internal server error")
+		}
+	}
+
+	if req.Admin != nil {
+		p.Admin = req.GetAdmin()
+	}
+
+	if err = s.repo.UpdateUser(ctx, id, p); err != nil {
+		return nil, repoError(err)
+	}
+
+	after, err := s.repo.UserByID(ctx, id)
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	s.recordAudit(ctx, audit.ActionUpdate, id, before, after)
+
+	return profileToUser(after), nil
+}
+
+func (s *service) DeleteUser(ctx context.Context, req *accountv1.DeleteUserRequest) (*accountv1.DeleteUserResponse, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid user id")
+	}
+
+	before, err := s.repo.UserByID(ctx, id)
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	if err = s.repo.DeleteUser(ctx, id); err != nil {
+		return nil, repoError(err)
+	}
+
+	s.recordAudit(ctx, audit.ActionDelete, id, before, model.Profile{})
+
+	return &accountv1.DeleteUserResponse{}, nil
+}
+
+func (s *service) recordAudit(ctx context.Context, action string, target uuid.UUID, before, after model.Profile) {
+	id, _ := auth.FromContext(ctx)
+
+	rec := model.AuditRecord{
+		Id:             uuid.New(),
+		Time:           time.Now().UTC(),
+		Actor:          id.Username,
+		ActorId:        id.UserID,
+		Impersonator:   id.Impersonator,
+		ImpersonatorId: id.ImpersonatorID,
+		Certificate:    id.Certificate,
+		Action:         action,
+		TargetId:       target,
+		Changes:        audit.Diff(before, after),
+		RequestId:      uuid.NewString(),
+	}
+
+	// host only, same as REST audit records
+	if p, ok := peer.FromContext(ctx); ok {
+		rec.SourceIP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(rec.SourceIP); err == nil {
+			rec.SourceIP = host
+		}
+	}
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("x-request-id"); len(v) > 0 && v[0] != "" {
+			rec.RequestId = v[0]
+		}
+	}
+
+	if err := s.audit.Write(rec); err != nil {
+		logger.FromContext(ctx).Error("failed to write audit record", "audit_id", rec.Id, "error", err)
+	}
+}
+
+func repoError(err error) error {
+	switch {
+	case errors.Is(err, storage.ErrNoUserID), errors.Is(err, storage.ErrNoUsername):
+		return status.Error(codes.NotFound, "user not found")
+	case errors.Is(err, storage.ErrUserExists):
+		return status.Error(codes.AlreadyExists, "user already exists")
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, "request canceled")
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, "deadline exceeded")
+	default:
+		return status.Error(codes.Internal, "internal server error")
+	}
+}
+
+func profileToUser(p model.Profile) *accountv1.User {
+	return &accountv1.User{
+		Id:       p.Id.String(),
+		Email:    p.Email,
+		Username: p.Username,
+		Admin:    p.Admin,
+	}
+}
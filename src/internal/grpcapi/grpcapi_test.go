@@ -0,0 +1,182 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/lekht/account-master/src/internal/audit"
+	"github.com/lekht/account-master/src/internal/auth"
+	"github.com/lekht/account-master/src/internal/hash"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/internal/token"
+	"github.com/lekht/account-master/src/pkg/api/accountv1"
+	"github.com/lekht/account-master/src/pkg/storage/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newClient(t *testing.T) accountv1.AccountServiceClient {
+	t.Helper()
+
+	m := mock.New()
+	for _, p := range []model.Profile{
+		{Username: "admin", Password: "admin", Admin: true},
+		{Username: "user", Password: "user"},
+	} {
+		p.Password, _ = hash.HashPassword(context.Background(), p.Password)
+		if _, err := m.CreateUser(context.Background(), p); err != nil {
+			t.Fatalf("CreateUser() error = %v", err)
+		}
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	tokens, _ := token.New(nil, 0)
+	srv := New(m, auth.New(m, tokens))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("grpc.NewClient() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return accountv1.NewAccountServiceClient(conn)
+}
+
+func basic(username, password string) context.Context {
+	creds := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Basic "+creds)
+}
+
+func TestService_Auth(t *testing.T) {
+	client := newClient(t)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		call func(context.Context) error
+		want codes.Code
+	}{
+		{
+			name: "no credentials",
+			ctx:  context.Background(),
+			call: func(ctx context.Context) error {
+				_, err := client.ListUsers(ctx, &accountv1.ListUsersRequest{})
+				return err
+			},
+			want: codes.Unauthenticated,
+		},
+		{
+			name: "wrong password",
+			ctx:  basic("user", "wrong"),
+			call: func(ctx context.Context) error {
+				_, err := client.ListUsers(ctx, &accountv1.ListUsersRequest{})
+				return err
+			},
+			want: codes.Unauthenticated,
+		},
+		{
+			name: "user lists users",
+			ctx:  basic("user", "user"),
+			call: func(ctx context.Context) error {
+				_, err := client.ListUsers(ctx, &accountv1.ListUsersRequest{})
+				return err
+			},
+			want: codes.OK,
+		},
+		{
+			name: "user can not create",
+			ctx:  basic("user", "user"),
+			call: func(ctx context.Context) error {
+				_, err := client.CreateUser(ctx, &accountv1.CreateUserRequest{Username: "new"})
+				return err
+			},
+			want: codes.PermissionDenied,
+		},
+		{
+			name: "admin creates existing user",
+			ctx:  basic("admin", "admin"),
+			call: func(ctx context.Context) error {
+				_, err := client.CreateUser(ctx, &accountv1.CreateUserRequest{Username: "user"})
+				return err
+			},
+			want: codes.AlreadyExists,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := status.Code(tt.call(tt.ctx)); got != tt.want {
+				t.Errorf("code = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestService_UpdateUser(t *testing.T) {
+	client := newClient(t)
+	ctx := basic("admin", "admin")
+
+	created, err := client.CreateUser(ctx, &accountv1.CreateUserRequest{Username: "new", Email: "new@mail.com", Admin: true})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	email := "changed@mail.com"
+	updated, err := client.UpdateUser(ctx, &accountv1.UpdateUserRequest{Id: created.GetId(), Email: &email})
+	if err != nil {
+		t.Fatalf("UpdateUser() error = %v", err)
+	}
+
+	// fields not set in request are kept
+	if updated.GetEmail() != email || updated.GetUsername() != "new" || !updated.GetAdmin() {
+		t.Errorf("UpdateUser() = %v", updated)
+	}
+
+	_, err = client.GetUser(ctx, &accountv1.GetUserRequest{Id: "not-uuid"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("GetUser() code = %s, want %s", status.Code(err), codes.InvalidArgument)
+	}
+}
+
+func TestService_RecordAuditSourceIP(t *testing.T) {
+	tests := []struct {
+		name string
+		addr net.Addr
+		want string
+	}{
+		{name: "ipv4", addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5555}, want: "10.0.0.1"},
+		{name: "ipv6", addr: &net.TCPAddr{IP: net.ParseIP("::1"), Port: 5555}, want: "::1"},
+		{name: "no port", addr: &net.UnixAddr{Name: "bufconn", Net: "unix"}, want: "bufconn"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mock.New()
+			s := &service{repo: m, audit: audit.NewStorageSink(m)}
+
+			ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: tt.addr})
+			s.recordAudit(ctx, audit.ActionCreate, uuid.New(), model.Profile{}, model.Profile{})
+
+			recs, err := s.audit.Query(audit.Filter{})
+			if err != nil || len(recs) != 1 {
+				t.Fatalf("Query() = %v, error = %v", recs, err)
+			}
+			if recs[0].SourceIP != tt.want {
+				t.Errorf("SourceIP = %q, want %q", recs[0].SourceIP, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package grpcapi
+
+import "github.com/lekht/account-master/src/internal/audit"
+
+type Option func(*service)
+
+// Audit sets sink of account mutation records.
+func Audit(sink audit.Sink) Option {
+	return func(s *service) {
+		s.audit = sink
+	}
+}
@@ -1,26 +1,40 @@
 package hash
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
 	"golang.org/x/crypto/bcrypt"
 )
 
 var ErrCompareHash = errors.New("failed to compare hash and password")
 
-func HashPassword(password string) (string, error) {
+var tracer = otel.Tracer("github.com/lekht/account-master/src/internal/hash")
+
+// HashPassword returns bcrypt hash of password. Context carries span
+// of the caller, hashing itself is not interruptible.
+func HashPassword(ctx context.Context, password string) (string, error) {
+	_, span := tracer.Start(ctx, "hash.HashPassword")
+	defer span.End()
+
 	pwd := strings.TrimSpace(password)
 
 	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(pwd), bcrypt.DefaultCost)
 	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
 		return "", fmt.Errorf("failed to hash password: %w", err)
 	}
 	return string(hashedBytes), nil
 }
 
-func CheckPassword(password, hashedPassword string) (bool, error) {
+func CheckPassword(ctx context.Context, password, hashedPassword string) (bool, error) {
+	_, span := tracer.Start(ctx, "hash.CheckPassword")
+	defer span.End()
+
 	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 	if err != nil {
 		return false, ErrCompareHash
@@ -28,3 +42,9 @@ func CheckPassword(password, hashedPassword string) (bool, error) {
 
 	return true, nil
 }
+
+// IsHash reports whether s is bcrypt hash, so it can be stored as is.
+func IsHash(s string) bool {
+	_, err := bcrypt.Cost([]byte(s))
+	return err == nil
+}
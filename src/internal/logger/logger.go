@@ -0,0 +1,88 @@
+// Package logger builds structured slog logger of the service and carries
+// request scoped logger in context.
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Redacted replaces values of sensitive attributes.
+const Redacted = "[REDACTED]"
+
+// sensitive attribute keys, compared in lower case
+var sensitive = map[string]bool{
+	"authorization": true,
+	"password":      true,
+	"password_hash": true,
+	"token":         true,
+	"cookie":        true,
+	"secret":        true,
+}
+
+// New returns logger writing to w with given level (debug, info, warn,
+// error) and format (json or text). Empty values mean info and json.
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWithLevel(w, lvl, format)
+}
+
+// NewWithLevel is New with level given as slog.Leveler, e.g. slog.LevelVar
+// changed while serving.
+func NewWithLevel(w io.Writer, level slog.Leveler, format string) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+
+	switch strings.ToLower(format) {
+	case "", "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q", format)
+	}
+}
+
+// ParseLevel parses level name, empty name means info.
+func ParseLevel(level string) (slog.Level, error) {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return lvl, fmt.Errorf("invalid log level %q", level)
+		}
+	}
+
+	return lvl, nil
+}
+
+// redact hides values of sensitive attributes, so credentials never reach
+// logs even when passed by mistake.
+func redact(_ []string, a slog.Attr) slog.Attr {
+	if sensitive[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, Redacted)
+	}
+
+	return a
+}
+
+type ctxKey struct{}
+
+// NewContext returns copy of ctx carrying l.
+func NewContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext returns logger carried by ctx or default logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return l
+	}
+
+	return slog.Default()
+}
@@ -0,0 +1,59 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		level   string
+		format  string
+		wantErr bool
+	}{
+		{name: "defaults"},
+		{name: "text debug", level: "debug", format: "text"},
+		{name: "invalid level", level: "loud", wantErr: true},
+		{name: "invalid format", format: "xml", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(&bytes.Buffer{}, tt.level, tt.format); (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNew_Redact(t *testing.T) {
+	var buf bytes.Buffer
+	l, _ := New(&buf, "info", "json")
+
+	l.Info("login",
+		"username", "alice",
+		"Authorization", "Basic YWxpY2U6c2VjcmV0",
+		slog.Group("user", "password", "secret"),
+	)
+
+	if strings.Contains(buf.String(), "secret") || strings.Contains(buf.String(), "YWxp") {
+		t.Fatalf("credentials logged: %s", buf.String())
+	}
+
+	var line struct {
+		Username      string
+		Authorization string
+		User          struct{ Password string }
+	}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if line.Username != "alice" || line.Authorization != Redacted || line.User.Password != Redacted {
+		t.Errorf("line = %+v", line)
+	}
+}
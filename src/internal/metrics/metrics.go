@@ -0,0 +1,133 @@
+// Package metrics exports Prometheus metrics of HTTP requests,
+// authentication, password checks and repository operations.
+package metrics
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "account_master"
+
+// accountsTimeout limits counting accounts on scrape.
+const accountsTimeout = 5 * time.Second
+
+// Metrics holds collectors of the service in own registry.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	auth            *prometheus.CounterVec
+	passwordCheck   prometheus.Histogram
+	repoDuration    *prometheus.HistogramVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route template and status.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method, route template and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		auth: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "auth_attempts_total",
+			Help:      "Authentication attempts by method, result and failure reason.",
+		}, []string{"method", "result", "reason"}),
+		passwordCheck: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "password_check_duration_seconds",
+			Help:      "Duration of bcrypt password checks.",
+			Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5},
+		}),
+		repoDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "repository_operation_duration_seconds",
+			Help:      "Repository operation latency by operation and result.",
+			Buckets:   []float64{.0005, .001, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"operation", "result"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.auth,
+		m.passwordCheck,
+		m.repoDuration,
+	)
+
+	return m
+}
+
+// Handler serves metrics in Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest records HTTP request handled by route template.
+func (m *Metrics) ObserveRequest(method, route string, status int, d time.Duration) {
+	code := strconv.Itoa(status)
+	m.requests.WithLabelValues(method, route, code).Inc()
+	m.requestDuration.WithLabelValues(method, route, code).Observe(d.Seconds())
+}
+
+// AuthSucceeded implements auth.Observer.
+func (m *Metrics) AuthSucceeded(method string) {
+	m.auth.WithLabelValues(method, "success", "").Inc()
+}
+
+// AuthFailed implements auth.Observer.
+func (m *Metrics) AuthFailed(method, reason string) {
+	m.auth.WithLabelValues(method, "failure", reason).Inc()
+}
+
+// ObservePasswordCheck implements auth.Observer.
+func (m *Metrics) ObservePasswordCheck(d time.Duration) {
+	m.passwordCheck.Observe(d.Seconds())
+}
+
+// ObserveRepository records repository operation.
+func (m *Metrics) ObserveRepository(op string, err error, d time.Duration) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+
+	m.repoDuration.WithLabelValues(op, result).Observe(d.Seconds())
+}
+
+// Accounts registers gauge of total accounts, counted on every scrape.
+func (m *Metrics) Accounts(count func(context.Context) (int, error)) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "accounts",
+		Help:      "Total number of accounts.",
+	}, func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), accountsTimeout)
+		defer cancel()
+
+		n, err := count(ctx)
+		if err != nil {
+			return math.NaN()
+		}
+
+		return float64(n)
+	}))
+}
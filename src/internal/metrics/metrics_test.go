@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage"
+	"github.com/lekht/account-master/src/pkg/storage/mock"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("scrape status = %d", w.Code)
+	}
+
+	return w.Body.String()
+}
+
+func TestMetrics_Repository(t *testing.T) {
+	m := New()
+	store := mock.New()
+	repo := m.Repository(store)
+	m.Accounts(func(ctx context.Context) (int, error) {
+		users, err := store.Users(ctx)
+		return len(users), err
+	})
+
+	ctx := context.Background()
+	_, _ = repo.CreateUser(ctx, model.Profile{Username: "alice"})
+	_, _ = repo.UserByName(ctx, "bob")
+
+	txRepo, ok := repo.(storage.Transactional)
+	if !ok {
+		t.Fatalf("Repository() lost unit of work support")
+	}
+
+	_ = txRepo.WithinTx(ctx, func(tx storage.Tx) error {
+		_, err := tx.CreateUser(ctx, model.Profile{Username: "bob"})
+		return err
+	})
+
+	m.ObserveRequest(http.MethodGet, "/v1/user/:id", http.StatusOK, time.Millisecond)
+	m.AuthFailed("basic", "invalid_credentials")
+	m.ObservePasswordCheck(50 * time.Millisecond)
+
+	body := scrape(t, m)
+
+	for _, want := range []string{
+		`account_master_repository_operation_duration_seconds_count{operation="create_user",result="success"} 2`,
+		`account_master_repository_operation_duration_seconds_count{operation="user_by_name",result="error"} 1`,
+		`account_master_repository_operation_duration_seconds_count{operation="within_tx",result="success"} 1`,
+		`account_master_http_requests_total{method="GET",route="/v1/user/:id",status="200"} 1`,
+		`account_master_auth_attempts_total{method="basic",reason="invalid_credentials",result="failure"} 1`,
+		`account_master_password_check_duration_seconds_count 1`,
+		`account_master_accounts 2`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics miss %s", want)
+		}
+	}
+}
+
+func TestMetrics_Accounts(t *testing.T) {
+	m := New()
+	m.Accounts(func(context.Context) (int, error) { return 0, errors.New("down") })
+
+	if body := scrape(t, m); !strings.Contains(body, "account_master_accounts NaN") {
+		t.Errorf("failed count is not reported as NaN")
+	}
+}
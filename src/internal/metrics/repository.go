@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage"
+)
+
+// Repository returns repo recording latency of every operation.
+// Result keeps unit of work support of repo.
+func (m *Metrics) Repository(repo storage.Repository) storage.Repository {
+	r := &repository{tx: tx{Tx: repo, m: m}, repo: repo}
+
+	if t, ok := repo.(storage.Transactional); ok {
+		return &txRepository{repository: r, t: t}
+	}
+
+	return r
+}
+
+// tx records operations of storage.Tx.
+type tx struct {
+	storage.Tx
+	m *Metrics
+}
+
+// observe starts timing of operation, returned func records it with
+// error err points to.
+func (t tx) observe(op string, err *error) func() {
+	start := time.Now()
+	return func() {
+		t.m.ObserveRepository(op, *err, time.Since(start))
+	}
+}
+
+func (t tx) UserByID(ctx context.Context, id uuid.UUID) (p model.Profile, err error) {
+	defer t.observe("user_by_id", &err)()
+	return t.Tx.UserByID(ctx, id)
+}
+
+func (t tx) UserByName(ctx context.Context, name string) (p model.Profile, err error) {
+	defer t.observe("user_by_name", &err)()
+	return t.Tx.UserByName(ctx, name)
+}
+
+func (t tx) CreateUser(ctx context.Context, u model.Profile) (p model.Profile, err error) {
+	defer t.observe("create_user", &err)()
+	return t.Tx.CreateUser(ctx, u)
+}
+
+func (t tx) UpdateUser(ctx context.Context, id uuid.UUID, u model.Profile) (err error) {
+	defer t.observe("update_user", &err)()
+	return t.Tx.UpdateUser(ctx, id, u)
+}
+
+func (t tx) DeleteUser(ctx context.Context, id uuid.UUID) (err error) {
+	defer t.observe("delete_user", &err)()
+	return t.Tx.DeleteUser(ctx, id)
+}
+
+type repository struct {
+	tx
+	repo storage.Repository
+}
+
+func (r *repository) Users(ctx context.Context) (users []model.Profile, err error) {
+	defer r.observe("users", &err)()
+	return r.repo.Users(ctx)
+}
+
+func (r *repository) UsersPage(ctx context.Context, after string, limit int) (users []model.Profile, err error) {
+	defer r.observe("users_page", &err)()
+	return r.repo.UsersPage(ctx, after, limit)
+}
+
+type txRepository struct {
+	*repository
+	t storage.Transactional
+}
+
+func (r *txRepository) WithinTx(ctx context.Context, fn func(tx storage.Tx) error) (err error) {
+	defer r.observe("within_tx", &err)()
+	return r.t.WithinTx(ctx, func(t storage.Tx) error {
+		return fn(tx{Tx: t, m: r.m})
+	})
+}
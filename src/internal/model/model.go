@@ -1,6 +1,10 @@
 package model
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 // 1. id (uuid, unique)
 // 2. email
@@ -15,3 +19,106 @@ type Profile struct {
 	Password string    `json:"password"`
 	Admin    bool      `json:"admin"`
 }
+
+// Change of single profile field. Secret values are redacted.
+type Change struct {
+	Field  string `json:"field"`
+	Before any    `json:"before,omitempty"`
+	After  any    `json:"after,omitempty"`
+}
+
+// AuditRecord describes single account mutation.
+type AuditRecord struct {
+	Id             uuid.UUID `json:"id"`
+	Time           time.Time `json:"time"`
+	Actor          string    `json:"actor"`
+	ActorId        uuid.UUID `json:"actor_id"`
+	Impersonator   string    `json:"impersonator,omitempty"`
+	ImpersonatorId uuid.UUID `json:"impersonator_id,omitzero"`
+	Action         string    `json:"action"`
+	TargetId       uuid.UUID `json:"target_id"`
+	Changes        []Change  `json:"changes"`
+	SourceIP       string    `json:"source_ip"`
+	RequestId      string    `json:"request_id"`
+	// Certificate is identity of client certificate actor authenticated
+	// with, e.g. uri:spiffe://example.org/svc/billing.
+	Certificate string `json:"certificate,omitempty"`
+}
+
+const (
+	EventUserCreated = "user.created"
+	EventUserUpdated = "user.updated"
+	EventUserDeleted = "user.deleted"
+)
+
+// Event of account lifecycle. User holds profile state after the change,
+// or before it for deleted users.
+type Event struct {
+	Id   uuid.UUID `json:"id"`
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	User Profile   `json:"user"`
+}
+
+// Webhook is endpoint notified about account lifecycle events.
+// Empty Events list subscribes to all events.
+type Webhook struct {
+	Id        uuid.UUID `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (w Webhook) Accepts(eventType string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+
+	for _, e := range w.Events {
+		if e == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+	DeliveryCanceled  = "canceled"
+)
+
+// Delivery of single event to single webhook.
+type Delivery struct {
+	Id            uuid.UUID `json:"id"`
+	WebhookId     uuid.UUID `json:"webhook_id"`
+	EventId       uuid.UUID `json:"event_id"`
+	EventType     string    `json:"event_type"`
+	Payload       []byte    `json:"-"`
+	Status        string    `json:"status"`
+	Attempts      []Attempt `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at,omitzero"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type Attempt struct {
+	Time       time.Time     `json:"time"`
+	StatusCode int           `json:"status_code,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Duration   time.Duration `json:"duration"`
+}
+
+// IdempotencyRecord keeps response of mutating request made with
+// Idempotency-Key, so retries of the request get the same response.
+type IdempotencyRecord struct {
+	Key         string
+	Fingerprint string
+	Completed   bool
+	Status      int
+	Header      map[string][]string
+	Body        []byte
+	ExpiresAt   time.Time
+}
@@ -0,0 +1,315 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// JSONPatch applies RFC 6902 patch to doc. Operations are applied in order,
+// the whole patch fails if any of them fails.
+func JSONPatch(doc, patch []byte) ([]byte, error) {
+	var target any
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, fmt.Errorf("patch - JSONPatch - decode document: %w", err)
+	}
+
+	// raw members are kept to tell missing "value" from null one
+	var ops []map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	for i, raw := range ops {
+		var err error
+		if target, err = applyOperation(target, raw); err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+
+	return json.Marshal(target)
+}
+
+func applyOperation(doc any, raw map[string]json.RawMessage) (any, error) {
+	var op, path string
+	if err := stringMember(raw, "op", &op); err != nil {
+		return nil, err
+	}
+
+	if err := stringMember(raw, "path", &path); err != nil {
+		return nil, err
+	}
+
+	tokens, err := parsePointer(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "add", "replace", "test":
+		rawValue, ok := raw["value"]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s requires value", ErrInvalidPatch, op)
+		}
+
+		var value any
+		if err = json.Unmarshal(rawValue, &value); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+
+		switch op {
+		case "add":
+			return update(doc, tokens, value, addMember)
+		case "replace":
+			return update(doc, tokens, value, replaceMember)
+		}
+
+		current, err := get(doc, tokens)
+		if err != nil {
+			return nil, err
+		}
+
+		if !reflect.DeepEqual(current, value) {
+			return nil, fmt.Errorf("%w: %s", ErrTestFailed, path)
+		}
+
+		return doc, nil
+	case "remove":
+		return update(doc, tokens, nil, removeMember)
+	case "move", "copy":
+		var from string
+		if err = stringMember(raw, "from", &from); err != nil {
+			return nil, err
+		}
+
+		fromTokens, err := parsePointer(from)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := get(doc, fromTokens)
+		if err != nil {
+			return nil, err
+		}
+
+		if op == "copy" {
+			return update(doc, tokens, deepCopy(value), addMember)
+		}
+
+		if from == path {
+			return doc, nil
+		}
+
+		if strings.HasPrefix(path, from+"/") {
+			return nil, fmt.Errorf("%w: can not move %s into its child", ErrInvalidPatch, from)
+		}
+
+		if doc, err = update(doc, fromTokens, nil, removeMember); err != nil {
+			return nil, err
+		}
+
+		return update(doc, tokens, value, addMember)
+	default:
+		return nil, fmt.Errorf("%w: unknown op %q", ErrInvalidPatch, op)
+	}
+}
+
+func stringMember(raw map[string]json.RawMessage, name string, dst *string) error {
+	v, ok := raw[name]
+	if !ok {
+		return fmt.Errorf("%w: missing %s", ErrInvalidPatch, name)
+	}
+
+	if err := json.Unmarshal(v, dst); err != nil {
+		return fmt.Errorf("%w: %s must be string", ErrInvalidPatch, name)
+	}
+
+	return nil
+}
+
+// parsePointer splits RFC 6901 JSON pointer to unescaped reference tokens.
+func parsePointer(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("%w: invalid pointer %q", ErrInvalidPatch, path)
+	}
+
+	tokens := strings.Split(path[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+func get(doc any, tokens []string) (any, error) {
+	for _, t := range tokens {
+		switch n := doc.(type) {
+		case map[string]any:
+			v, ok := n[t]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", ErrPath, t)
+			}
+			doc = v
+		case []any:
+			i, err := arrayIndex(t, len(n)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = n[i]
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrPath, t)
+		}
+	}
+
+	return doc, nil
+}
+
+// memberFunc changes member key of container parent and returns new container.
+type memberFunc func(parent any, key string, value any) (any, error)
+
+// root is parent of the whole document. It is distinct from nil, so null
+// member on the way to target location is not mistaken for the document.
+type root struct{}
+
+// update walks to the parent of target location and applies fn to it.
+// Empty tokens refer to the whole document.
+func update(doc any, tokens []string, value any, fn memberFunc) (any, error) {
+	if len(tokens) == 0 {
+		return fn(root{}, "", value)
+	}
+
+	if len(tokens) == 1 {
+		return fn(doc, tokens[0], value)
+	}
+
+	switch n := doc.(type) {
+	case map[string]any:
+		child, ok := n[tokens[0]]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrPath, tokens[0])
+		}
+
+		child, err := update(child, tokens[1:], value, fn)
+		if err != nil {
+			return nil, err
+		}
+		n[tokens[0]] = child
+
+		return n, nil
+	case []any:
+		i, err := arrayIndex(tokens[0], len(n)-1)
+		if err != nil {
+			return nil, err
+		}
+
+		child, err := update(n[i], tokens[1:], value, fn)
+		if err != nil {
+			return nil, err
+		}
+		n[i] = child
+
+		return n, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrPath, tokens[0])
+	}
+}
+
+func addMember(parent any, key string, value any) (any, error) {
+	switch n := parent.(type) {
+	case root:
+		return value, nil
+	case map[string]any:
+		n[key] = value
+		return n, nil
+	case []any:
+		i := len(n)
+		if key != "-" {
+			var err error
+			if i, err = arrayIndex(key, len(n)); err != nil {
+				return nil, err
+			}
+		}
+
+		n = append(n, nil)
+		copy(n[i+1:], n[i:])
+		n[i] = value
+
+		return n, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrPath, key)
+	}
+}
+
+func removeMember(parent any, key string, _ any) (any, error) {
+	switch n := parent.(type) {
+	case map[string]any:
+		if _, ok := n[key]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrPath, key)
+		}
+		delete(n, key)
+
+		return n, nil
+	case []any:
+		i, err := arrayIndex(key, len(n)-1)
+		if err != nil {
+			return nil, err
+		}
+
+		return append(n[:i], n[i+1:]...), nil
+	default:
+		return nil, fmt.Errorf("%w: can not remove %q", ErrPath, key)
+	}
+}
+
+func replaceMember(parent any, key string, value any) (any, error) {
+	switch n := parent.(type) {
+	case root:
+		return value, nil
+	case map[string]any:
+		if _, ok := n[key]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrPath, key)
+		}
+		n[key] = value
+
+		return n, nil
+	case []any:
+		i, err := arrayIndex(key, len(n)-1)
+		if err != nil {
+			return nil, err
+		}
+		n[i] = value
+
+		return n, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrPath, key)
+	}
+}
+
+// arrayIndex parses array reference token not greater than limit.
+func arrayIndex(token string, limit int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') || strings.Trim(token, "0123456789") != "" {
+		return 0, fmt.Errorf("%w: invalid index %q", ErrPath, token)
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil || i > limit {
+		return 0, fmt.Errorf("%w: invalid index %q", ErrPath, token)
+	}
+
+	return i, nil
+}
+
+func deepCopy(v any) any {
+	b, _ := json.Marshal(v)
+
+	var c any
+	_ = json.Unmarshal(b, &c)
+
+	return c
+}
@@ -0,0 +1,162 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{name: "replace member", doc: `{"a":"b"}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{name: "add member", doc: `{"a":"b"}`, patch: `{"b":"c"}`, want: `{"a":"b","b":"c"}`},
+		{name: "null removes", doc: `{"a":"b","b":"c"}`, patch: `{"a":null}`, want: `{"b":"c"}`},
+		{name: "array replaced", doc: `{"a":["b"]}`, patch: `{"a":["c","d"]}`, want: `{"a":["c","d"]}`},
+		{name: "nested", doc: `{"a":{"b":"c","d":"e"}}`, patch: `{"a":{"b":null,"f":"g"}}`, want: `{"a":{"d":"e","f":"g"}}`},
+		{name: "non object patch", doc: `{"a":"b"}`, patch: `["c"]`, want: `["c"]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("MergePatch() error = %v", err)
+			}
+
+			assertJSON(t, got, tt.want)
+		})
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		doc         string
+		patch       string
+		want        string
+		errExpected error
+	}{
+		{
+			name:  "add and replace",
+			doc:   `{"a":"b"}`,
+			patch: `[{"op":"add","path":"/c","value":1},{"op":"replace","path":"/a","value":null}]`,
+			want:  `{"a":null,"c":1}`,
+		},
+		{
+			name:  "array operations",
+			doc:   `{"a":[1,2]}`,
+			patch: `[{"op":"add","path":"/a/1","value":3},{"op":"add","path":"/a/-","value":4},{"op":"remove","path":"/a/0"}]`,
+			want:  `{"a":[3,2,4]}`,
+		},
+		{
+			name:  "move and copy",
+			doc:   `{"a":{"b":"c"}}`,
+			patch: `[{"op":"copy","from":"/a/b","path":"/d"},{"op":"move","from":"/a","path":"/e"}]`,
+			want:  `{"d":"c","e":{"b":"c"}}`,
+		},
+		{
+			name:  "escaped pointer",
+			doc:   `{"a/b":1,"m~n":2}`,
+			patch: `[{"op":"test","path":"/a~1b","value":1},{"op":"remove","path":"/m~0n"}]`,
+			want:  `{"a/b":1}`,
+		},
+		{
+			name:        "test fails",
+			doc:         `{"a":"b"}`,
+			patch:       `[{"op":"replace","path":"/a","value":"c"},{"op":"test","path":"/a","value":"b"}]`,
+			errExpected: ErrTestFailed,
+		},
+		{
+			name:        "remove missing",
+			doc:         `{"a":"b"}`,
+			patch:       `[{"op":"remove","path":"/c"}]`,
+			errExpected: ErrPath,
+		},
+		{
+			name:        "replace missing",
+			doc:         `{"a":"b"}`,
+			patch:       `[{"op":"replace","path":"/c","value":1}]`,
+			errExpected: ErrPath,
+		},
+		{
+			name:        "missing value",
+			doc:         `{"a":"b"}`,
+			patch:       `[{"op":"add","path":"/c"}]`,
+			errExpected: ErrInvalidPatch,
+		},
+		{
+			name:        "unknown op",
+			doc:         `{"a":"b"}`,
+			patch:       `[{"op":"merge","path":"/a","value":1}]`,
+			errExpected: ErrInvalidPatch,
+		},
+		{
+			name:        "move into child",
+			doc:         `{"a":{"b":1}}`,
+			patch:       `[{"op":"move","from":"/a","path":"/a/c"}]`,
+			errExpected: ErrInvalidPatch,
+		},
+		{
+			name:  "replace whole document",
+			doc:   `{"a":"b"}`,
+			patch: `[{"op":"replace","path":"","value":{"c":1}}]`,
+			want:  `{"c":1}`,
+		},
+		{
+			name:        "add under null member",
+			doc:         `{"a":null}`,
+			patch:       `[{"op":"add","path":"/a/b","value":1}]`,
+			errExpected: ErrPath,
+		},
+		{
+			name:        "replace under null member",
+			doc:         `{"a":{"b":null}}`,
+			patch:       `[{"op":"replace","path":"/a/b/c","value":1}]`,
+			errExpected: ErrPath,
+		},
+		{
+			name:        "index out of range",
+			doc:         `{"a":[1]}`,
+			patch:       `[{"op":"add","path":"/a/2","value":1}]`,
+			errExpected: ErrPath,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := JSONPatch([]byte(tt.doc), []byte(tt.patch))
+			if tt.errExpected != nil {
+				if !errors.Is(err, tt.errExpected) {
+					t.Fatalf("JSONPatch() error = %v, want %v", err, tt.errExpected)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("JSONPatch() error = %v", err)
+			}
+
+			assertJSON(t, got, tt.want)
+		})
+	}
+}
+
+func assertJSON(t *testing.T, got []byte, want string) {
+	t.Helper()
+
+	var g, w any
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("Unmarshal(%s) error = %v", got, err)
+	}
+	_ = json.Unmarshal([]byte(want), &w)
+
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
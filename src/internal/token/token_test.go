@@ -0,0 +1,65 @@
+package token
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestIssuer_Parse(t *testing.T) {
+	actor, subject := uuid.New(), uuid.New()
+
+	issuer, _ := New([]byte("secret"), time.Minute)
+	valid, _, err := issuer.Issue(actor, subject)
+	if err != nil {
+		t.Fatalf("Issuer.Issue() error = %v", err)
+	}
+
+	stale, _ := New([]byte("secret"), time.Minute)
+	stale.ttl = -time.Minute
+	expired, _, _ := stale.Issue(actor, subject)
+
+	other, _ := New([]byte("other"), time.Minute)
+	foreign, _, _ := other.Issue(actor, subject)
+
+	tests := []struct {
+		name        string
+		token       string
+		errExpected error
+	}{
+		{
+			name:  "valid token",
+			token: valid,
+		},
+		{
+			name:        "foreign key",
+			token:       foreign,
+			errExpected: ErrSignature,
+		},
+		{
+			name:        "expired token",
+			token:       expired,
+			errExpected: ErrExpired,
+		},
+		{
+			name:        "malformed token",
+			token:       "garbage",
+			errExpected: ErrMalformed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := issuer.Parse(tt.token)
+			if !errors.Is(err, tt.errExpected) {
+				t.Fatalf("Issuer.Parse() error = %v, want %v", err, tt.errExpected)
+			}
+
+			if err == nil && (got.Actor != actor || got.Subject != subject) {
+				t.Errorf("Issuer.Parse() = %+v, want actor %s subject %s", got, actor, subject)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/lekht/account-master/src/internal/tracing")
+
+// Repository returns repo creating span for every operation.
+// Result keeps unit of work support of repo.
+func Repository(repo storage.Repository) storage.Repository {
+	r := &repository{tx: tx{Tx: repo}, repo: repo}
+
+	if t, ok := repo.(storage.Transactional); ok {
+		return &txRepository{repository: r, t: t}
+	}
+
+	return r
+}
+
+// start starts span of repository operation, returned func ends it
+// with error err points to.
+func start(ctx context.Context, op string, err *error, attrs ...attribute.KeyValue) (context.Context, func()) {
+	ctx, span := tracer.Start(ctx, "repository."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+
+	return ctx, func() {
+		if *err != nil {
+			span.RecordError(*err)
+			span.SetStatus(codes.Error, (*err).Error())
+		}
+		span.End()
+	}
+}
+
+// tx traces operations of storage.Tx.
+type tx struct {
+	storage.Tx
+}
+
+func (t tx) UserByID(ctx context.Context, id uuid.UUID) (p model.Profile, err error) {
+	ctx, end := start(ctx, "UserByID", &err, attribute.String("user.id", id.String()))
+	defer end()
+	return t.Tx.UserByID(ctx, id)
+}
+
+func (t tx) UserByName(ctx context.Context, name string) (p model.Profile, err error) {
+	ctx, end := start(ctx, "UserByName", &err)
+	defer end()
+	return t.Tx.UserByName(ctx, name)
+}
+
+func (t tx) CreateUser(ctx context.Context, u model.Profile) (p model.Profile, err error) {
+	ctx, end := start(ctx, "CreateUser", &err)
+	defer end()
+	return t.Tx.CreateUser(ctx, u)
+}
+
+func (t tx) UpdateUser(ctx context.Context, id uuid.UUID, u model.Profile) (err error) {
+	ctx, end := start(ctx, "UpdateUser", &err, attribute.String("user.id", id.String()))
+	defer end()
+	return t.Tx.UpdateUser(ctx, id, u)
+}
+
+func (t tx) DeleteUser(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, end := start(ctx, "DeleteUser", &err, attribute.String("user.id", id.String()))
+	defer end()
+	return t.Tx.DeleteUser(ctx, id)
+}
+
+type repository struct {
+	tx
+	repo storage.Repository
+}
+
+func (r *repository) Users(ctx context.Context) (users []model.Profile, err error) {
+	ctx, end := start(ctx, "Users", &err)
+	defer end()
+	return r.repo.Users(ctx)
+}
+
+func (r *repository) UsersPage(ctx context.Context, after string, limit int) (users []model.Profile, err error) {
+	ctx, end := start(ctx, "UsersPage", &err, attribute.Int("page.limit", limit))
+	defer end()
+	return r.repo.UsersPage(ctx, after, limit)
+}
+
+type txRepository struct {
+	*repository
+	t storage.Transactional
+}
+
+// WithinTx traces unit of work, operations of fn are traced too.
+func (r *txRepository) WithinTx(ctx context.Context, fn func(tx storage.Tx) error) (err error) {
+	ctx, end := start(ctx, "WithinTx", &err)
+	defer end()
+	return r.t.WithinTx(ctx, func(t storage.Tx) error {
+		return fn(tx{Tx: t})
+	})
+}
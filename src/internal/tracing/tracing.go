@@ -0,0 +1,62 @@
+// Package tracing sets up OpenTelemetry tracing of the service. Spans are
+// created through global tracer provider, so packages take tracers from
+// otel.Tracer and tests install provider with in-memory exporter.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// ServiceName reported in resource of every span.
+const ServiceName = "account-master"
+
+// New creates tracer provider exporting spans to OTLP/HTTP endpoint
+// (host:port). Ratio sets share of sampled root spans, child spans follow
+// parent decision. Caller must Shutdown provider to flush spans.
+func New(ctx context.Context, endpoint string, insecure bool, ratio float64) (*sdktrace.TracerProvider, error) {
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint)}
+	if insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create otlp exporter: %w", err)
+	}
+
+	return NewProvider(sdktrace.WithBatcher(exporter), ratio), nil
+}
+
+// NewProvider creates tracer provider of the service with span processor
+// p, e.g. sdktrace.WithSyncer of in-memory exporter in tests.
+func NewProvider(p sdktrace.TracerProviderOption, ratio float64) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		p,
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(ServiceName))),
+	)
+}
+
+// Install makes tp global tracer provider and sets W3C traceparent and
+// baggage propagation.
+func Install(tp *sdktrace.TracerProvider) {
+	otel.SetTracerProvider(tp)
+	SetPropagator()
+}
+
+// SetPropagator sets W3C traceparent and baggage propagation, so incoming
+// trace context is kept even when spans are not exported.
+func SetPropagator() {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+}
@@ -0,0 +1,63 @@
+package webhook
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Option configures dispatcher. Non-positive values keep defaults.
+type Option func(*Dispatcher)
+
+func PollInterval(interval time.Duration) Option {
+	return func(d *Dispatcher) {
+		if interval > 0 {
+			d.pollInterval = interval
+		}
+	}
+}
+
+func Timeout(timeout time.Duration) Option {
+	return func(d *Dispatcher) {
+		if timeout > 0 {
+			d.client.Timeout = timeout
+		}
+	}
+}
+
+func MaxAttempts(n int) Option {
+	return func(d *Dispatcher) {
+		if n > 0 {
+			d.maxAttempts = n
+		}
+	}
+}
+
+// ShutdownTimeout limits how long Shutdown waits for in-flight delivery.
+func ShutdownTimeout(timeout time.Duration) Option {
+	return func(d *Dispatcher) {
+		if timeout > 0 {
+			d.shutdownTimeout = timeout
+		}
+	}
+}
+
+func Backoff(base, max time.Duration) Option {
+	return func(d *Dispatcher) {
+		if base > 0 {
+			d.backoff = base
+		}
+
+		if max > 0 {
+			d.maxBackoff = max
+		}
+	}
+}
+
+// Logger sets logger of dispatcher. Default logger is used otherwise.
+func Logger(l *slog.Logger) Option {
+	return func(d *Dispatcher) {
+		if l != nil {
+			d.logger = l
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage"
+)
+
+const (
+	defaultPollInterval = time.Second
+	defaultTimeout      = 5 * time.Second
+	defaultMaxAttempts  = 8
+	defaultBackoff      = time.Second
+	defaultMaxBackoff   = 10 * time.Minute
+	// defaultShutdownTimeout lets in-flight delivery finish within its
+	// own timeout
+	defaultShutdownTimeout = defaultTimeout
+
+	HeaderSignature = "X-Webhook-Signature"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+)
+
+// Store keeps webhooks, outbox events and deliveries.
+type Store interface {
+	CreateWebhook(model.Webhook) (model.Webhook, error)
+	Webhooks() ([]model.Webhook, error)
+	WebhookByID(uuid.UUID) (model.Webhook, error)
+	DeleteWebhook(uuid.UUID) error
+
+	PendingEvents() ([]model.Event, error)
+	AckEvent(uuid.UUID) error
+
+	CreateDeliveries([]model.Delivery) error
+	DueDeliveries(time.Time) ([]model.Delivery, error)
+	// UpdateDelivery fails with storage.ErrNoWebhookID when webhook of
+	// delivery is deleted, so canceled delivery is not revived.
+	UpdateDelivery(model.Delivery) error
+	Deliveries(uuid.UUID) ([]model.Delivery, error)
+}
+
+type payload struct {
+	Id   uuid.UUID `json:"id"`
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Data user      `json:"data"`
+}
+
+type user struct {
+	Id       uuid.UUID `json:"id"`
+	Email    string    `json:"email"`
+	Username string    `json:"username"`
+	Admin    bool      `json:"admin"`
+}
+
+// Dispatcher moves events from storage outbox to webhook deliveries and
+// sends them with exponential backoff. Events are acknowledged only after
+// deliveries are stored, so they survive restart at any point.
+type Dispatcher struct {
+	store  Store
+	client *http.Client
+
+	pollInterval time.Duration
+	maxAttempts  int
+	backoff      time.Duration
+	maxBackoff   time.Duration
+
+	shutdownTimeout time.Duration
+
+	logger *slog.Logger
+
+	// stop ends poll loop, cancel aborts in-flight sends
+	stop   chan struct{}
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewDispatcher(store Store, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		store:        store,
+		client:       &http.Client{Timeout: defaultTimeout},
+		pollInterval: defaultPollInterval,
+		maxAttempts:  defaultMaxAttempts,
+		backoff:      defaultBackoff,
+		maxBackoff:   defaultMaxBackoff,
+
+		shutdownTimeout: defaultShutdownTimeout,
+		logger:          slog.Default(),
+		stop:            make(chan struct{}),
+		done:            make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+
+	go d.run(ctx)
+
+	return d
+}
+
+// Shutdown stops polling and waits for in-flight delivery to finish.
+// Delivery still running after shutdown timeout is aborted and retried
+// after restart.
+func (d *Dispatcher) Shutdown() {
+	close(d.stop)
+	defer d.cancel()
+
+	timer := time.NewTimer(d.shutdownTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-d.done:
+	case <-timer.C:
+		d.cancel()
+		<-d.done
+	}
+}
+
+func (d *Dispatcher) stopping() bool {
+	select {
+	case <-d.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (d *Dispatcher) run(ctx context.Context) {
+	defer close(d.done)
+
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+			if err := d.fanOut(); err != nil {
+				d.logger.Error("webhook fan-out failed", "error", err)
+			}
+
+			if err := d.deliverDue(ctx); err != nil {
+				d.logger.Error("webhook delivery failed", "error", err)
+			}
+		}
+	}
+}
+
+// fanOut creates delivery for every webhook subscribed to outbox event.
+func (d *Dispatcher) fanOut() error {
+	events, err := d.store.PendingEvents()
+	if err != nil {
+		return fmt.Errorf("failed to get pending events: %w", err)
+	}
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	hooks, err := d.store.Webhooks()
+	if err != nil {
+		return fmt.Errorf("failed to get webhooks: %w", err)
+	}
+
+	for _, e := range events {
+		body, err := json.Marshal(payload{
+			Id:   e.Id,
+			Type: e.Type,
+			Time: e.Time,
+			Data: user{
+				Id:       e.User.Id,
+				Email:    e.User.Email,
+				Username: e.User.Username,
+				Admin:    e.User.Admin,
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal event %s: %w", e.Id, err)
+		}
+
+		ds := make([]model.Delivery, 0, len(hooks))
+		for _, w := range hooks {
+			if !w.Accepts(e.Type) {
+				continue
+			}
+
+			ds = append(ds, model.Delivery{
+				Id:            uuid.New(),
+				WebhookId:     w.Id,
+				EventId:       e.Id,
+				EventType:     e.Type,
+				Payload:       body,
+				Status:        model.DeliveryPending,
+				Attempts:      []model.Attempt{},
+				NextAttemptAt: time.Now().UTC(),
+				CreatedAt:     time.Now().UTC(),
+			})
+		}
+
+		if err = d.store.CreateDeliveries(ds); err != nil {
+			return fmt.Errorf("failed to create deliveries of event %s: %w", e.Id, err)
+		}
+
+		if err = d.store.AckEvent(e.Id); err != nil {
+			return fmt.Errorf("failed to ack event %s: %w", e.Id, err)
+		}
+	}
+
+	return nil
+}
+
+func (d *Dispatcher) deliverDue(ctx context.Context) error {
+	due, err := d.store.DueDeliveries(time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to get due deliveries: %w", err)
+	}
+
+	for _, dl := range due {
+		if d.stopping() || ctx.Err() != nil {
+			return nil
+		}
+
+		w, err := d.store.WebhookByID(dl.WebhookId)
+		if err != nil {
+			d.logger.Warn("failed to get webhook of delivery",
+				"webhook_id", dl.WebhookId, "delivery_id", dl.Id, "error", err)
+			continue
+		}
+
+		a := d.send(ctx, w, dl)
+		dl.Attempts = append(dl.Attempts, a)
+
+		switch {
+		case a.Error == "":
+			dl.Status = model.DeliverySucceeded
+			dl.NextAttemptAt = time.Time{}
+		case len(dl.Attempts) >= d.maxAttempts:
+			dl.Status = model.DeliveryFailed
+			dl.NextAttemptAt = time.Time{}
+		default:
+			dl.NextAttemptAt = a.Time.Add(d.delay(len(dl.Attempts)))
+		}
+
+		// webhook deleted while sending, its deliveries are canceled
+		err = d.store.UpdateDelivery(dl)
+		if errors.Is(err, storage.ErrNoWebhookID) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("failed to update delivery %s: %w", dl.Id, err)
+		}
+	}
+
+	return nil
+}
+
+func (d *Dispatcher) send(ctx context.Context, w model.Webhook, dl model.Delivery) model.Attempt {
+	a := model.Attempt{Time: time.Now().UTC()}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(dl.Payload))
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+
+	ts := strconv.FormatInt(a.Time.Unix(), 10)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, dl.EventType)
+	req.Header.Set(HeaderDelivery, dl.Id.String())
+	req.Header.Set(HeaderTimestamp, ts)
+	req.Header.Set(HeaderSignature, Sign(w.Secret, ts, dl.Payload))
+
+	resp, err := d.client.Do(req)
+	a.Duration = time.Since(a.Time)
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	defer resp.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	a.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		a.Error = "unexpected status " + resp.Status
+	}
+
+	return a
+}
+
+// delay returns backoff before next attempt: backoff * 2^(attempts-1),
+// capped by max backoff.
+func (d *Dispatcher) delay(attempts int) time.Duration {
+	delay := d.backoff
+	for i := 1; i < attempts && delay < d.maxBackoff; i++ {
+		delay *= 2
+	}
+
+	return min(delay, d.maxBackoff)
+}
+
+// Sign returns signature of webhook request: hex encoded HMAC-SHA256 of
+// timestamp and body joined with dot, prefixed with "sha256=".
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,128 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage/mock"
+)
+
+func TestDispatcher(t *testing.T) {
+	const secret = "secret"
+
+	var calls atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// first attempt fails to check retry
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, _ := io.ReadAll(req.Body)
+		want := Sign(secret, req.Header.Get(HeaderTimestamp), body)
+		if req.Header.Get(HeaderSignature) != want {
+			t.Errorf("signature = %q, want %q", req.Header.Get(HeaderSignature), want)
+		}
+
+		if e := req.Header.Get(HeaderEvent); e != model.EventUserCreated {
+			t.Errorf("event = %q, want %q", e, model.EventUserCreated)
+		}
+	}))
+	defer srv.Close()
+
+	m := mock.New()
+	w, _ := m.CreateWebhook(model.Webhook{URL: srv.URL, Events: []string{model.EventUserCreated}, Secret: secret})
+
+	if _, err := m.CreateUser(context.Background(), model.Profile{Username: "test"}); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	d := NewDispatcher(m, PollInterval(10*time.Millisecond), Backoff(10*time.Millisecond, 10*time.Millisecond))
+	defer d.Shutdown()
+
+	var ds []model.Delivery
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		ds, _ = m.Deliveries(w.Id)
+		if len(ds) > 0 && ds[0].Status != model.DeliveryPending {
+			break
+		}
+	}
+
+	if len(ds) != 1 {
+		t.Fatalf("got %d deliveries, want 1", len(ds))
+	}
+
+	if ds[0].Status != model.DeliverySucceeded || len(ds[0].Attempts) != 2 {
+		t.Errorf("delivery status = %s with %d attempts, want %s with 2", ds[0].Status, len(ds[0].Attempts), model.DeliverySucceeded)
+	}
+
+	if events, _ := m.PendingEvents(); len(events) != 0 {
+		t.Errorf("got %d pending events, want 0", len(events))
+	}
+}
+
+func TestDispatcher_Shutdown(t *testing.T) {
+	tests := []struct {
+		name          string
+		timeout       time.Duration
+		deleteWebhook bool
+		wantStatus    string
+		wantAttempts  int
+	}{
+		{name: "waits for in-flight delivery", timeout: time.Second, wantStatus: model.DeliverySucceeded, wantAttempts: 1},
+		{name: "aborts delivery after timeout", timeout: 10 * time.Millisecond, wantStatus: model.DeliveryPending, wantAttempts: 1},
+		{name: "webhook deleted while sending", timeout: time.Second, deleteWebhook: true, wantStatus: model.DeliveryCanceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := make(chan struct{})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				close(started)
+				select {
+				case <-time.After(200 * time.Millisecond):
+				case <-req.Context().Done():
+				}
+			}))
+			defer srv.Close()
+
+			m := mock.New()
+			w, _ := m.CreateWebhook(model.Webhook{URL: srv.URL, Events: []string{model.EventUserCreated}})
+			if _, err := m.CreateUser(context.Background(), model.Profile{Username: "test"}); err != nil {
+				t.Fatalf("CreateUser() error = %v", err)
+			}
+
+			d := NewDispatcher(m, PollInterval(10*time.Millisecond), ShutdownTimeout(tt.timeout))
+
+			select {
+			case <-started:
+			case <-time.After(2 * time.Second):
+				t.Fatal("delivery was not sent")
+			}
+
+			if tt.deleteWebhook {
+				if err := m.DeleteWebhook(w.Id); err != nil {
+					t.Fatalf("DeleteWebhook() error = %v", err)
+				}
+			}
+
+			d.Shutdown()
+
+			ds, _ := m.Deliveries(w.Id)
+			if len(ds) != 1 {
+				t.Fatalf("got %d deliveries, want 1", len(ds))
+			}
+
+			if ds[0].Status != tt.wantStatus || len(ds[0].Attempts) != tt.wantAttempts {
+				t.Errorf("delivery status = %s with %d attempts, want %s with %d", ds[0].Status, len(ds[0].Attempts), tt.wantStatus, tt.wantAttempts)
+			}
+		})
+	}
+}
@@ -2,29 +2,59 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
+	"os"
 
 	"github.com/lekht/account-master/src/config"
 	"github.com/lekht/account-master/src/docs"
 	"github.com/lekht/account-master/src/internal/app"
+	"gopkg.in/yaml.v3"
 )
 
-var conf config.Config
-
 // @title						Account Master
 // @version					1.0
 // @decsription				CRUD account service
-// @BasePath					/
+// @BasePath					/v1
 // @securityDefinitions.basic	BasicAuth
+// @securityDefinitions.apikey	BearerAuth
+// @in							header
+// @name						Authorization
 func main() {
-	docs.SwaggerInfo.BasePath = "/"
+	docs.SwaggerInfo.BasePath = "/v1"
 
 	path := flag.String("config", "./config.yaml", "path to config file")
+	printConfig := flag.Bool("print-config", false, "print effective config with secrets redacted and exit")
+	checkConfig := flag.Bool("check-config", false, "validate config and exit")
+	flags := config.NewFlags(flag.CommandLine)
 	flag.Parse()
 
-	if err := config.Load(*path, &conf); err != nil {
+	conf, err := config.Resolve(*path, os.LookupEnv, flags)
+	if err != nil {
 		log.Fatalf("failed to load config: %v\n", err)
 	}
 
-	app.Run(&conf)
+	if *printConfig {
+		// secrets redact themselves
+		out, err := yaml.Marshal(conf)
+		if err != nil {
+			log.Fatalf("failed to print config: %v\n", err)
+		}
+		fmt.Print(string(out))
+		return
+	}
+
+	if err = conf.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if *checkConfig {
+		fmt.Println("config is valid")
+		return
+	}
+
+	app.Run(&conf, func() (config.Config, error) {
+		return config.Resolve(*path, os.LookupEnv, flags)
+	})
 }
@@ -0,0 +1,21 @@
+package grpcserver
+
+import (
+	"net"
+	"strconv"
+	"time"
+)
+
+type Option func(*Server)
+
+func Adress(host string, port int) Option {
+	return func(s *Server) {
+		s.addr = net.JoinHostPort(host, strconv.Itoa(port))
+	}
+}
+
+func ShutdownTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.shutdownTimeout = timeout
+	}
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"crypto/tls"
 	"net"
 	"strconv"
 	"time"
@@ -31,3 +32,60 @@ func ShutdownTimeout(timeout time.Duration) Option {
 		s.shutdownTimeout = timeout
 	}
 }
+
+// DrainDelay sets how long server keeps serving after Shutdown starts,
+// so load balancers notice failed readiness before listener is closed.
+func DrainDelay(delay time.Duration) Option {
+	return func(s *Server) {
+		s.drainDelay = delay
+	}
+}
+
+// OnShutdown adds hook called when Shutdown starts, before drain delay.
+func OnShutdown(fn func()) Option {
+	return func(s *Server) {
+		s.onShutdown = append(s.onShutdown, fn)
+	}
+}
+
+// TLS serves HTTPS with certificate and key from PEM files. Files are
+// reloaded when they change, so certificates rotate without restart.
+func TLS(certFile, keyFile string) Option {
+	return func(s *Server) {
+		s.ensureTLS()
+		s.tls.certFile, s.tls.keyFile = certFile, keyFile
+	}
+}
+
+// ClientCA enables mutual TLS in given mode, verifying client certificates
+// against CAs from PEM files. CA files are reloaded like certificate.
+// Requires TLS.
+func ClientCA(mode ClientAuth, caFiles ...string) Option {
+	return func(s *Server) {
+		s.ensureTLS()
+		s.tls.clientAuth, s.tls.clientCAs = mode, caFiles
+	}
+}
+
+// MinTLSVersion sets minimum accepted TLS version, TLS 1.2 by default.
+func MinTLSVersion(version uint16) Option {
+	return func(s *Server) {
+		s.ensureTLS()
+		s.tls.minVersion = version
+	}
+}
+
+// CipherSuites limits cipher suites of TLS 1.2, Go defaults are used when
+// empty. TLS 1.3 suites are not configurable.
+func CipherSuites(ids ...uint16) Option {
+	return func(s *Server) {
+		s.ensureTLS()
+		s.tls.cipherSuites = ids
+	}
+}
+
+func (s *Server) ensureTLS() {
+	if s.tls == nil {
+		s.tls = &tlsSettings{minVersion: tls.VersionTLS12, reloadInterval: reloadInterval}
+	}
+}
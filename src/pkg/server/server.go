@@ -17,6 +17,14 @@ type Server struct {
 	server          *http.Server
 	notify          chan error
 	shutdownTimeout time.Duration
+
+	// For drainDelay after Shutdown starts server keeps serving, so
+	// readiness probes failed by OnShutdown hooks stop sending traffic.
+	drainDelay time.Duration
+	onShutdown []func()
+
+	// tls is nil for plain HTTP
+	tls *tlsSettings
 }
 
 func New(handler http.Handler, opts ...Option) *Server {
@@ -28,8 +36,9 @@ func New(handler http.Handler, opts ...Option) *Server {
 	}
 
 	s := &Server{
-		server: httpserver,
-		notify: make(chan error, 1),
+		server:          httpserver,
+		notify:          make(chan error, 1),
+		shutdownTimeout: defaultShutdownTimeout,
 	}
 
 	for _, opt := range opts {
@@ -42,8 +51,24 @@ func New(handler http.Handler, opts ...Option) *Server {
 }
 
 func (s *Server) start() {
+	serve := s.server.ListenAndServe
+
+	if s.tls != nil {
+		cfg, err := s.tls.config()
+		if err != nil {
+			s.notify <- err
+			close(s.notify)
+			return
+		}
+
+		s.server.TLSConfig = cfg
+		serve = func() error {
+			return s.server.ListenAndServeTLS("", "")
+		}
+	}
+
 	go func() {
-		s.notify <- s.server.ListenAndServe()
+		s.notify <- serve()
 		close(s.notify)
 	}()
 }
@@ -52,7 +77,15 @@ func (s *Server) Notify() <-chan error {
 	return s.notify
 }
 
+// Shutdown calls OnShutdown hooks and waits drain delay, then gracefully
+// stops server within shutdown timeout.
 func (s *Server) Shutdown() error {
+	for _, fn := range s.onShutdown {
+		fn()
+	}
+
+	time.Sleep(s.drainDelay)
+
 	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
 	defer cancel()
 
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// reloadInterval limits how often certificate files are checked for changes.
+const reloadInterval = 10 * time.Second
+
+// ClientAuth is mode of mutual TLS.
+type ClientAuth int
+
+const (
+	// ClientAuthOff does not request client certificate.
+	ClientAuthOff ClientAuth = iota
+	// ClientAuthOptional verifies client certificate when one is sent.
+	ClientAuthOptional
+	// ClientAuthRequired rejects handshake without valid client certificate.
+	ClientAuthRequired
+)
+
+// ParseClientAuth parses mode name: off, optional or required. Empty name
+// means off.
+func ParseClientAuth(s string) (ClientAuth, error) {
+	switch s {
+	case "", "off":
+		return ClientAuthOff, nil
+	case "optional":
+		return ClientAuthOptional, nil
+	case "required":
+		return ClientAuthRequired, nil
+	default:
+		return ClientAuthOff, fmt.Errorf("unknown client auth mode %q", s)
+	}
+}
+
+// ParseTLSVersion parses TLS version, 1.2 or 1.3. Empty version means 1.2.
+func ParseTLSVersion(s string) (uint16, error) {
+	switch s {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS version %q", s)
+	}
+}
+
+// ParseCipherSuites returns ids of cipher suites named as in crypto/tls,
+// e.g. TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256. Insecure suites are rejected.
+func ParseCipherSuites(names ...string) ([]uint16, error) {
+	known := make(map[string]uint16)
+	for _, cs := range tls.CipherSuites() {
+		known[cs.Name] = cs.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+type tlsSettings struct {
+	certFile     string
+	keyFile      string
+	clientAuth   ClientAuth
+	clientCAs    []string
+	minVersion   uint16
+	cipherSuites []uint16
+
+	reloadInterval time.Duration
+}
+
+// config returns TLS config of server. Certificate and client CAs are
+// loaded now and reloaded on handshake after their files change.
+func (t *tlsSettings) config() (*tls.Config, error) {
+	certs, err := newReloader(t.reloadInterval, func() (*tls.Certificate, error) {
+		cert, err := tls.LoadX509KeyPair(t.certFile, t.keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load certificate: %w", err)
+		}
+		return &cert, nil
+	}, t.certFile, t.keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &tls.Config{
+		MinVersion:   t.minVersion,
+		CipherSuites: t.cipherSuites,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return certs.get(), nil
+		},
+	}
+
+	if t.clientAuth == ClientAuthOff {
+		return cfg, nil
+	}
+
+	if len(t.clientCAs) == 0 {
+		return nil, errors.New("client CA is required for mutual TLS")
+	}
+
+	cas, err := newReloader(t.reloadInterval, func() (*x509.CertPool, error) {
+		return loadCertPool(t.clientCAs)
+	}, t.clientCAs...)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	if t.clientAuth == ClientAuthRequired {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	// ClientCAs has no callback, so every handshake gets copy of config
+	// with current pool
+	base := cfg.Clone()
+	cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c := base.Clone()
+		c.ClientCAs = cas.get()
+		return c, nil
+	}
+
+	return cfg, nil
+}
+
+// loadCertPool reads PEM certificates of files into pool.
+func loadCertPool(files []string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates in client CA %s", f)
+		}
+	}
+
+	return pool, nil
+}
+
+// reloader keeps value loaded from files and loads it again when
+// modification time of any file changes. Files are checked at most once
+// per interval. Failed reload keeps previous value and is retried, so
+// half-written files never break handshakes.
+type reloader[T any] struct {
+	files    []string
+	load     func() (T, error)
+	interval time.Duration
+
+	mu      sync.Mutex
+	value   T
+	modTime time.Time
+	checked time.Time
+}
+
+func newReloader[T any](interval time.Duration, load func() (T, error), files ...string) (*reloader[T], error) {
+	r := &reloader[T]{files: files, load: load, interval: interval}
+
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return nil, err
+	}
+
+	if r.value, err = load(); err != nil {
+		return nil, err
+	}
+	r.modTime, r.checked = modTime, time.Now()
+
+	return r, nil
+}
+
+func (r *reloader[T]) get() T {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(r.checked) < r.interval {
+		return r.value
+	}
+	r.checked = now
+
+	modTime, err := r.latestModTime()
+	if err != nil || modTime.Equal(r.modTime) {
+		return r.value
+	}
+
+	if v, err := r.load(); err == nil {
+		r.value, r.modTime = v, modTime
+	}
+
+	return r.value
+}
+
+func (r *reloader[T]) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, f := range r.files {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+
+	return latest, nil
+}
@@ -0,0 +1,217 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// issue creates certificate for cn signed by parent, self-signed when
+// parent is nil.
+func issue(t *testing.T, cn string, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+		tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate() error = %v", err)
+	}
+
+	return &testCert{cert: cert, key: key}
+}
+
+func (c *testCert) certPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw})
+}
+
+func (c *testCert) keyPEM(t *testing.T) []byte {
+	der, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey() error = %v", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func (c *testCert) tlsCert() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}
+}
+
+func writeFile(t *testing.T, path string, data []byte, mod time.Time) {
+	t.Helper()
+
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	if err := os.Chtimes(path, mod, mod); err != nil {
+		t.Fatalf("Chtimes() error = %v", err)
+	}
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer lis.Close()
+
+	return lis.Addr().(*net.TCPAddr).Port
+}
+
+func TestServer_TLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, "ca", nil)
+	srvCert := issue(t, "server-1", ca)
+	clientCert := issue(t, "client", ca)
+	stranger := issue(t, "stranger", issue(t, "other-ca", nil))
+
+	certFile, keyFile, caFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt")
+	loaded := time.Now().Add(-time.Minute)
+	writeFile(t, certFile, srvCert.certPEM(), loaded)
+	writeFile(t, keyFile, srvCert.keyPEM(t), loaded)
+	writeFile(t, caFile, ca.certPEM(), loaded)
+
+	port := freePort(t)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.VerifiedChains) > 0 {
+			_, _ = w.Write([]byte(r.TLS.VerifiedChains[0][0].Subject.CommonName))
+		}
+	})
+	s := New(handler,
+		Adress("127.0.0.1", port),
+		TLS(certFile, keyFile),
+		ClientCA(ClientAuthRequired, caFile),
+		func(s *Server) { s.tls.reloadInterval = 0 },
+	)
+	t.Cleanup(func() { _ = s.Shutdown() })
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	url := "https://127.0.0.1:" + strconv.Itoa(port)
+
+	// get returns CN of server and client certificates seen by handshake.
+	get := func(client *testCert) (server, body string, err error) {
+		cfg := &tls.Config{RootCAs: roots}
+		if client != nil {
+			cfg.Certificates = []tls.Certificate{client.tlsCert()}
+		}
+
+		c := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg, DisableKeepAlives: true}}
+		resp, err := c.Get(url)
+		if err != nil {
+			return "", "", err
+		}
+		defer resp.Body.Close()
+
+		buf := make([]byte, 64)
+		n, _ := resp.Body.Read(buf)
+
+		return resp.TLS.PeerCertificates[0].Subject.CommonName, string(buf[:n]), nil
+	}
+
+	// wait for listener
+	var err error
+	for range 50 {
+		if _, _, err = get(clientCert); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+
+	t.Run("client certificate", func(t *testing.T) {
+		if _, body, err := get(clientCert); err != nil || body != "client" {
+			t.Errorf("GET body = %q, error = %v", body, err)
+		}
+	})
+
+	t.Run("no client certificate", func(t *testing.T) {
+		if _, _, err := get(nil); err == nil {
+			t.Error("GET without client certificate succeeded")
+		}
+	})
+
+	t.Run("untrusted client certificate", func(t *testing.T) {
+		if _, _, err := get(stranger); err == nil {
+			t.Error("GET with untrusted client certificate succeeded")
+		}
+	})
+
+	t.Run("certificate reload", func(t *testing.T) {
+		next := issue(t, "server-2", ca)
+		writeFile(t, certFile, next.certPEM(), time.Now())
+		writeFile(t, keyFile, next.keyPEM(t), time.Now())
+
+		if server, _, err := get(clientCert); err != nil || server != "server-2" {
+			t.Errorf("server certificate = %q, error = %v", server, err)
+		}
+	})
+}
+
+func TestParseCipherSuites(t *testing.T) {
+	tests := []struct {
+		name    string
+		names   []string
+		wantErr bool
+	}{
+		{name: "secure", names: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}},
+		{name: "insecure", names: []string{"TLS_RSA_WITH_RC4_128_SHA"}, wantErr: true},
+		{name: "unknown", names: []string{"TLS_NULL"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCipherSuites(tt.names...); (err != nil) != tt.wantErr {
+				t.Errorf("ParseCipherSuites() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
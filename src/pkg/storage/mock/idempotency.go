@@ -0,0 +1,54 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/lekht/account-master/src/internal/model"
+)
+
+// ReserveIdempotencyKey stores rec unless unexpired record with the same key
+// exists. It returns stored record and whether rec was reserved.
+func (m *Mock) ReserveIdempotencyKey(rec model.IdempotencyRecord, now time.Time) (model.IdempotencyRecord, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, r := range m.idempotency {
+		if !now.Before(r.ExpiresAt) {
+			delete(m.idempotency, key)
+		}
+	}
+
+	if r, ok := m.idempotency[rec.Key]; ok {
+		return r, false, nil
+	}
+
+	m.idempotency[rec.Key] = rec
+
+	return rec, true, nil
+}
+
+func (m *Mock) CompleteIdempotencyKey(rec model.IdempotencyRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.idempotency[rec.Key]; !ok {
+		return ErrNoIdempotency
+	}
+
+	m.idempotency[rec.Key] = rec
+
+	return nil
+}
+
+func (m *Mock) DeleteIdempotencyKey(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.idempotency[key]; !ok {
+		return ErrNoIdempotency
+	}
+
+	delete(m.idempotency, key)
+
+	return nil
+}
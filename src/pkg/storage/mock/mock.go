@@ -1,35 +1,70 @@
 package mock
 
 import (
-	"errors"
+	"context"
 	"sort"
 	"sync"
 
 	"github.com/google/uuid"
 	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage"
 )
 
 var (
-	ErrNoUserID   = errors.New("no user with this id")
-	ErrUserExists = errors.New("user already exists")
-	ErrNoUsername = errors.New("no user with this username")
+	ErrNoUserID      = storage.ErrNoUserID
+	ErrUserExists    = storage.ErrUserExists
+	ErrNoUsername    = storage.ErrNoUsername
+	ErrNoWebhookID   = storage.ErrNoWebhookID
+	ErrNoDeliveryID  = storage.ErrNoDeliveryID
+	ErrNoOutboxEvent = storage.ErrNoOutboxEvent
+	ErrNoIdempotency = storage.ErrNoIdempotency
 )
 
+// Mock is in-memory storage. Every user method checks its context
+// before taking the lock, so canceled requests do no work.
 type Mock struct {
 	users map[uuid.UUID]model.Profile
+	audit []model.AuditRecord
+
+	// outbox holds events written together with user changes
+	// until they are fanned out to webhook deliveries
+	outbox     []model.Event
+	webhooks   map[uuid.UUID]model.Webhook
+	deliveries map[uuid.UUID]model.Delivery
+	publishers []Publisher
+
+	idempotency map[string]model.IdempotencyRecord
 
 	mu sync.RWMutex
 }
 
-func New() *Mock {
+func New(opts ...Option) *Mock {
 	m := Mock{
-		users: make(map[uuid.UUID]model.Profile),
+		users:      make(map[uuid.UUID]model.Profile),
+		webhooks:   make(map[uuid.UUID]model.Webhook),
+		deliveries: make(map[uuid.UUID]model.Delivery),
+
+		idempotency: make(map[string]model.IdempotencyRecord),
+	}
+
+	for _, opt := range opts {
+		opt(&m)
 	}
 
 	return &m
 }
 
-func (m *Mock) Users() ([]model.Profile, error) {
+// Ping reports whether storage can serve requests. In-memory storage is
+// always available while ctx is alive.
+func (m *Mock) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func (m *Mock) Users(ctx context.Context) ([]model.Profile, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
@@ -49,89 +84,174 @@ func (m *Mock) Users() ([]model.Profile, error) {
 	return users, nil
 }
 
-func (m *Mock) CreateUser(p model.Profile) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// UsersPage returns up to limit users with username greater than after,
+// sorted by username. Empty after starts from the first user.
+func (m *Mock) UsersPage(ctx context.Context, after string, limit int) ([]model.Profile, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
+	users := make([]model.Profile, 0, min(limit, len(m.users)))
 	for _, usr := range m.users {
-		if p.Username == usr.Username {
-			return ErrUserExists
+		if usr.Username > after {
+			users = append(users, usr)
 		}
 	}
 
-	for {
-		p.Id = uuid.New()
-		if _, ok := m.users[p.Id]; !ok {
-			break
-		}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+
+	if len(users) > limit {
+		users = users[:limit]
 	}
 
-	m.users[p.Id] = p
+	return users, nil
+}
 
-	return nil
+// CreateUser stores p with generated id and returns stored profile.
+func (m *Mock) CreateUser(ctx context.Context, p model.Profile) (model.Profile, error) {
+	if err := ctx.Err(); err != nil {
+		return model.Profile{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.createUser(p)
+	if err != nil {
+		return model.Profile{}, err
+	}
+
+	m.publish(model.EventUserCreated, p)
+
+	return p, nil
 }
 
-func (m *Mock) UserByID(id uuid.UUID) (model.Profile, error) {
+func (m *Mock) UserByID(ctx context.Context, id uuid.UUID) (model.Profile, error) {
+	if err := ctx.Err(); err != nil {
+		return model.Profile{}, err
+	}
+
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	user, exists := m.users[id]
-	if !exists {
-		return model.Profile{}, ErrNoUserID
+	return m.userByID(id)
+}
+
+func (m *Mock) UpdateUser(ctx context.Context, id uuid.UUID, p model.Profile) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	return user, nil
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	usr, err := m.updateUser(id, p)
+	if err != nil {
+		return err
+	}
+
+	m.publish(model.EventUserUpdated, usr)
+
+	return nil
 }
 
-func (m *Mock) UpdateUser(id uuid.UUID, p model.Profile) error {
+func (m *Mock) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	usr, exists := m.users[id]
-	if !exists {
-		return ErrNoUserID
+	usr, err := m.deleteUser(id)
+	if err != nil {
+		return err
 	}
 
-	// updates only non default value
-	if p.Email != "" {
-		usr.Email = p.Email
+	m.publish(model.EventUserDeleted, usr)
+
+	return nil
+}
+
+func (m *Mock) UserByName(ctx context.Context, name string) (model.Profile, error) {
+	if err := ctx.Err(); err != nil {
+		return model.Profile{}, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.userByName(name)
+}
+
+// createUser, updateUser and deleteUser change users without events.
+// They and lookups below must be called under lock.
+
+func (m *Mock) createUser(p model.Profile) (model.Profile, error) {
+	for _, usr := range m.users {
+		if p.Username == usr.Username {
+			return model.Profile{}, ErrUserExists
+		}
 	}
 
-	if p.Username != "" {
-		usr.Username = p.Username
+	for {
+		p.Id = uuid.New()
+		if _, ok := m.users[p.Id]; !ok {
+			break
+		}
 	}
 
-	if p.Password != "" {
-		usr.Password = p.Password
+	m.users[p.Id] = p
+
+	return p, nil
+}
+
+func (m *Mock) updateUser(id uuid.UUID, p model.Profile) (model.Profile, error) {
+	if _, exists := m.users[id]; !exists {
+		return model.Profile{}, ErrNoUserID
 	}
 
-	if p.Admin != usr.Admin {
-		usr.Admin = p.Admin
+	for uid, usr := range m.users {
+		if uid != id && usr.Username == p.Username {
+			return model.Profile{}, ErrUserExists
+		}
 	}
 
+	// replaces whole profile, callers merge changes themselves
+	usr := p
+	usr.Id = id
+
 	m.users[id] = usr
 
-	return nil
+	return usr, nil
 }
 
-func (m *Mock) DeleteUser(id uuid.UUID) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	_, exists := m.users[id]
+func (m *Mock) deleteUser(id uuid.UUID) (model.Profile, error) {
+	usr, exists := m.users[id]
 	if !exists {
-		return ErrNoUserID
+		return model.Profile{}, ErrNoUserID
 	}
 
 	delete(m.users, id)
 
-	return nil
+	return usr, nil
 }
 
-func (m *Mock) UserByName(name string) (model.Profile, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+func (m *Mock) userByID(id uuid.UUID) (model.Profile, error) {
+	user, exists := m.users[id]
+	if !exists {
+		return model.Profile{}, ErrNoUserID
+	}
+
+	return user, nil
+}
 
+func (m *Mock) userByName(name string) (model.Profile, error) {
 	for _, user := range m.users {
 		if user.Username == name {
 			return user, nil
@@ -140,3 +260,22 @@ func (m *Mock) UserByName(name string) (model.Profile, error) {
 
 	return model.Profile{}, ErrNoUsername
 }
+
+func (m *Mock) CreateAuditRecord(r model.AuditRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.audit = append(m.audit, r)
+
+	return nil
+}
+
+func (m *Mock) AuditRecords() ([]model.AuditRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	records := make([]model.AuditRecord, len(m.audit))
+	copy(records, m.audit)
+
+	return records, nil
+}
@@ -1,12 +1,15 @@
 package mock
 
 import (
+	"context"
 	"errors"
 	"reflect"
 	"testing"
 
 	"github.com/google/uuid"
 	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage"
+	"github.com/lekht/account-master/src/pkg/storage/storagetest"
 )
 
 func TestMock_Users(t *testing.T) {
@@ -49,7 +52,7 @@ func TestMock_Users(t *testing.T) {
 				tt.mockSetup(m)
 			}
 
-			gotUsers, err := m.Users()
+			gotUsers, err := m.Users(context.Background())
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Users() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -93,7 +96,7 @@ func TestMock_CreateUser(t *testing.T) {
 				tt.mockSetup(m)
 			}
 
-			err := m.CreateUser(tt.user)
+			got, err := m.CreateUser(context.Background(), tt.user)
 			if (err != nil) != tt.wantErr {
 				if err != nil {
 					t.Errorf("CreateUser() error = %v, wantErr %v", err, tt.wantErr)
@@ -106,6 +109,11 @@ func TestMock_CreateUser(t *testing.T) {
 				if !errors.Is(err, tt.errExpected) {
 					t.Errorf("Mock.CreateUser() not expected error\n")
 				}
+				return
+			}
+
+			if stored := m.users[got.Id]; got.Id == uuid.Nil || stored != got {
+				t.Errorf("Mock.CreateUser() = %+v, stored %+v", got, stored)
 			}
 		})
 	}
@@ -144,7 +152,7 @@ func TestMock_UserByID(t *testing.T) {
 			tt.mockSetup(m)
 		}
 
-		got, err := m.UserByID(tt.id)
+		got, err := m.UserByID(context.Background(), tt.id)
 		if (err != nil) != tt.wantErr {
 			t.Errorf("Mock.UserByID() error = %v, wantErr %v", err, tt.wantErr)
 			continue
@@ -157,9 +165,11 @@ func TestMock_UserByID(t *testing.T) {
 
 func TestMock_UpdateUser(t *testing.T) {
 	id := uuid.New()
+	otherID := uuid.New()
 	existingUser := model.Profile{Id: id, Username: "test", Email: "old@example.com", Admin: false, Password: "oldPassword"}
 	m := New()
 	m.users[id] = existingUser
+	m.users[otherID] = model.Profile{Id: otherID, Username: "other"}
 
 	tests := []struct {
 		name        string
@@ -170,36 +180,35 @@ func TestMock_UpdateUser(t *testing.T) {
 		wantUser    model.Profile
 	}{
 		{
-			name:        "update email",
+			name:        "replace profile",
 			id:          id,
-			profile:     model.Profile{Email: "new@example.com"},
+			profile:     model.Profile{Username: "new_username", Email: "new@example.com", Admin: true, Password: "new_password"},
 			wantErr:     false,
 			errExpected: nil,
-			wantUser:    model.Profile{Id: id, Username: "test", Email: "new@example.com", Admin: false, Password: "oldPassword"},
+			wantUser:    model.Profile{Id: id, Username: "new_username", Email: "new@example.com", Admin: true, Password: "new_password"},
 		},
 		{
-			name:        "update username",
+			name:        "clear email",
 			id:          id,
-			profile:     model.Profile{Username: "new_username"},
+			profile:     model.Profile{Username: "test", Password: "oldPassword"},
 			wantErr:     false,
 			errExpected: nil,
-			wantUser:    model.Profile{Id: id, Username: "new_username", Email: "old@example.com", Admin: false, Password: "oldPassword"},
+			wantUser:    model.Profile{Id: id, Username: "test", Email: "", Admin: false, Password: "oldPassword"},
 		},
 		{
-			name:        "update password",
+			name:        "id in profile is ignored",
 			id:          id,
-			profile:     model.Profile{Password: "new_password"},
+			profile:     model.Profile{Id: otherID, Username: "test", Email: "old@example.com", Password: "oldPassword"},
 			wantErr:     false,
 			errExpected: nil,
-			wantUser:    model.Profile{Id: id, Username: "test", Email: "old@example.com", Admin: false, Password: "new_password"},
+			wantUser:    existingUser,
 		},
 		{
-			name:        "update admin status",
+			name:        "username taken",
 			id:          id,
-			profile:     model.Profile{Admin: true},
-			wantErr:     false,
-			errExpected: nil,
-			wantUser:    model.Profile{Id: id, Username: "test", Email: "old@example.com", Admin: true, Password: "oldPassword"},
+			profile:     model.Profile{Username: "other"},
+			errExpected: ErrUserExists,
+			wantErr:     true,
 		},
 		{
 			name:        "user does not exist",
@@ -212,7 +221,7 @@ func TestMock_UpdateUser(t *testing.T) {
 	}
 	for _, tt := range tests {
 
-		err := m.UpdateUser(tt.id, tt.profile)
+		err := m.UpdateUser(context.Background(), tt.id, tt.profile)
 		if (err != nil) != tt.wantErr {
 			if err != nil {
 				t.Errorf("Mock.UpdateUser() error occured, but not expected\n")
@@ -226,6 +235,11 @@ func TestMock_UpdateUser(t *testing.T) {
 			if !errors.Is(err, tt.errExpected) {
 				t.Errorf("Mock.UpdateUser() not expected error\n")
 			}
+			continue
+		}
+
+		if got := m.users[tt.id]; got != tt.wantUser {
+			t.Errorf("%s: Mock.UpdateUser() user = %+v, want %+v", tt.name, got, tt.wantUser)
 		}
 	}
 }
@@ -256,7 +270,7 @@ func TestMock_DeleteUser(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		err := m.DeleteUser(tt.id)
+		err := m.DeleteUser(context.Background(), tt.id)
 		if (err != nil) != tt.wantErr {
 			t.Errorf("Mock.DeleteUser() error = %v, wantErr %v", err, tt.wantErr)
 			continue
@@ -314,7 +328,7 @@ func TestMock_UserByName(t *testing.T) {
 		if tt.mockSetup != nil {
 			tt.mockSetup(m)
 		}
-		got, err := m.UserByName(tt.userName)
+		got, err := m.UserByName(context.Background(), tt.userName)
 		if !reflect.DeepEqual(got, tt.want) {
 			t.Errorf("Mock.UserByName() = %v, want %v", got, tt.want)
 		}
@@ -340,3 +354,166 @@ func TestMock_UserByName(t *testing.T) {
 		}
 	}
 }
+
+func TestMock_UsersPage(t *testing.T) {
+	m := New()
+	for _, name := range []string{"carol", "alice", "dave", "bob"} {
+		if _, err := m.CreateUser(context.Background(), model.Profile{Username: name}); err != nil {
+			t.Fatalf("CreateUser() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		after string
+		limit int
+		want  []string
+	}{
+		{name: "first page", limit: 3, want: []string{"alice", "bob", "carol"}},
+		{name: "next page", after: "carol", limit: 3, want: []string{"dave"}},
+		{name: "after last", after: "dave", limit: 3, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, err := m.UsersPage(context.Background(), tt.after, tt.limit)
+			if err != nil {
+				t.Fatalf("UsersPage() error = %v", err)
+			}
+
+			got := make([]string, 0, len(users))
+			for _, u := range users {
+				got = append(got, u.Username)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UsersPage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type recordingPublisher struct {
+	events []model.Event
+}
+
+func (p *recordingPublisher) Publish(e model.Event) {
+	p.events = append(p.events, e)
+}
+
+func TestMock_WithinTx(t *testing.T) {
+	errFail := errors.New("fail")
+
+	tests := []struct {
+		name       string
+		fn         func(tx storage.Tx) error
+		errExpect  error
+		panics     bool
+		wantUsers  []string
+		wantEvents int
+	}{
+		{
+			name: "commit",
+			fn: func(tx storage.Tx) error {
+				if _, err := tx.CreateUser(context.Background(), model.Profile{Username: "new"}); err != nil {
+					return err
+				}
+
+				old, err := tx.UserByName(context.Background(), "old")
+				if err != nil {
+					return err
+				}
+
+				return tx.DeleteUser(context.Background(), old.Id)
+			},
+			wantUsers:  []string{"new"},
+			wantEvents: 2,
+		},
+		{
+			name: "rollback",
+			fn: func(tx storage.Tx) error {
+				if _, err := tx.CreateUser(context.Background(), model.Profile{Username: "new"}); err != nil {
+					return err
+				}
+
+				// sees its own changes
+				if _, err := tx.UserByName(context.Background(), "new"); err != nil {
+					return err
+				}
+
+				return errFail
+			},
+			errExpect: errFail,
+			wantUsers: []string{"old"},
+		},
+		{
+			name: "storage error rolls back",
+			fn: func(tx storage.Tx) error {
+				old, _ := tx.UserByName(context.Background(), "old")
+				if err := tx.UpdateUser(context.Background(), old.Id, model.Profile{Username: "renamed"}); err != nil {
+					return err
+				}
+
+				_, err := tx.CreateUser(context.Background(), model.Profile{Username: "renamed"})
+				return err
+			},
+			errExpect: ErrUserExists,
+			wantUsers: []string{"old"},
+		},
+		{
+			name: "panic rolls back",
+			fn: func(tx storage.Tx) error {
+				if _, err := tx.CreateUser(context.Background(), model.Profile{Username: "new"}); err != nil {
+					return err
+				}
+
+				panic("fail")
+			},
+			panics:    true,
+			wantUsers: []string{"old"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pub := &recordingPublisher{}
+			m := New(WithPublisher(pub))
+			if _, err := m.CreateUser(context.Background(), model.Profile{Username: "old"}); err != nil {
+				t.Fatalf("CreateUser() error = %v", err)
+			}
+			pub.events = nil
+
+			var err error
+			func() {
+				defer func() {
+					if p := recover(); (p != nil) != tt.panics {
+						t.Errorf("WithinTx() panic = %v, want panic %v", p, tt.panics)
+					}
+				}()
+
+				err = m.WithinTx(context.Background(), tt.fn)
+			}()
+			if !errors.Is(err, tt.errExpect) {
+				t.Fatalf("WithinTx() error = %v, want %v", err, tt.errExpect)
+			}
+
+			users, _ := m.Users(context.Background())
+			got := make([]string, 0, len(users))
+			for _, u := range users {
+				got = append(got, u.Username)
+			}
+
+			if !reflect.DeepEqual(got, tt.wantUsers) {
+				t.Errorf("users = %v, want %v", got, tt.wantUsers)
+			}
+
+			if len(pub.events) != tt.wantEvents {
+				t.Errorf("published %d events, want %d", len(pub.events), tt.wantEvents)
+			}
+		})
+	}
+}
+
+func TestMock_Conformance(t *testing.T) {
+	storagetest.Run(t, func() storage.Repository { return New() })
+}
@@ -0,0 +1,17 @@
+package mock
+
+import "github.com/lekht/account-master/src/internal/model"
+
+// Publisher receives every event written to outbox. It is called under
+// storage lock and must not block.
+type Publisher interface {
+	Publish(model.Event)
+}
+
+type Option func(*Mock)
+
+func WithPublisher(p Publisher) Option {
+	return func(m *Mock) {
+		m.publishers = append(m.publishers, p)
+	}
+}
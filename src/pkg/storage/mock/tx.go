@@ -0,0 +1,139 @@
+package mock
+
+import (
+	"context"
+	"maps"
+
+	"github.com/google/uuid"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage"
+)
+
+// tx runs under write lock of mock, so it uses unlocked helpers.
+// Events are kept until commit, rolled back changes are never published.
+type tx struct {
+	m      *Mock
+	events []model.Event
+	done   bool
+}
+
+// WithinTx runs fn as unit of work under the single lock of mock. If fn
+// returns error, panics or ctx is canceled before commit, every change made
+// through tx is rolled back. fn must not call other methods of mock.
+func (m *Mock) WithinTx(ctx context.Context, fn func(storage.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := maps.Clone(m.users)
+
+	t := &tx{m: m}
+	defer func() { t.done = true }()
+
+	defer func() {
+		if p := recover(); p != nil {
+			m.users = snapshot
+			panic(p)
+		}
+	}()
+
+	err := fn(t)
+	if err == nil {
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		m.users = snapshot
+		return err
+	}
+
+	for _, e := range t.events {
+		m.publish(e.Type, e.User)
+	}
+
+	return nil
+}
+
+func (t *tx) UserByID(ctx context.Context, id uuid.UUID) (model.Profile, error) {
+	if err := ctx.Err(); err != nil {
+		return model.Profile{}, err
+	}
+
+	if t.done {
+		return model.Profile{}, storage.ErrTxDone
+	}
+
+	return t.m.userByID(id)
+}
+
+func (t *tx) UserByName(ctx context.Context, name string) (model.Profile, error) {
+	if err := ctx.Err(); err != nil {
+		return model.Profile{}, err
+	}
+
+	if t.done {
+		return model.Profile{}, storage.ErrTxDone
+	}
+
+	return t.m.userByName(name)
+}
+
+func (t *tx) CreateUser(ctx context.Context, p model.Profile) (model.Profile, error) {
+	if err := ctx.Err(); err != nil {
+		return model.Profile{}, err
+	}
+
+	if t.done {
+		return model.Profile{}, storage.ErrTxDone
+	}
+
+	p, err := t.m.createUser(p)
+	if err != nil {
+		return model.Profile{}, err
+	}
+
+	t.events = append(t.events, model.Event{Type: model.EventUserCreated, User: p})
+
+	return p, nil
+}
+
+func (t *tx) UpdateUser(ctx context.Context, id uuid.UUID, p model.Profile) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if t.done {
+		return storage.ErrTxDone
+	}
+
+	usr, err := t.m.updateUser(id, p)
+	if err != nil {
+		return err
+	}
+
+	t.events = append(t.events, model.Event{Type: model.EventUserUpdated, User: usr})
+
+	return nil
+}
+
+func (t *tx) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if t.done {
+		return storage.ErrTxDone
+	}
+
+	usr, err := t.m.deleteUser(id)
+	if err != nil {
+		return err
+	}
+
+	t.events = append(t.events, model.Event{Type: model.EventUserDeleted, User: usr})
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package mock
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lekht/account-master/src/internal/model"
+)
+
+// publish appends event to outbox and passes it to publishers.
+// Must be called under write lock.
+func (m *Mock) publish(eventType string, p model.Profile) {
+	e := model.Event{
+		Id:   uuid.New(),
+		Type: eventType,
+		Time: time.Now().UTC(),
+		User: p,
+	}
+
+	m.outbox = append(m.outbox, e)
+
+	for _, pub := range m.publishers {
+		pub.Publish(e)
+	}
+}
+
+func (m *Mock) PendingEvents() ([]model.Event, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	events := make([]model.Event, len(m.outbox))
+	copy(events, m.outbox)
+
+	return events, nil
+}
+
+func (m *Mock) AckEvent(id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, e := range m.outbox {
+		if e.Id == id {
+			m.outbox = append(m.outbox[:i], m.outbox[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrNoOutboxEvent
+}
+
+func (m *Mock) CreateWebhook(w model.Webhook) (model.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for {
+		w.Id = uuid.New()
+		if _, ok := m.webhooks[w.Id]; !ok {
+			break
+		}
+	}
+
+	w.CreatedAt = time.Now().UTC()
+	m.webhooks[w.Id] = w
+
+	return w, nil
+}
+
+func (m *Mock) Webhooks() ([]model.Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	hooks := make([]model.Webhook, 0, len(m.webhooks))
+	for _, w := range m.webhooks {
+		hooks = append(hooks, w)
+	}
+
+	sort.Slice(hooks, func(i, j int) bool {
+		return hooks[i].CreatedAt.Before(hooks[j].CreatedAt)
+	})
+
+	return hooks, nil
+}
+
+func (m *Mock) WebhookByID(id uuid.UUID) (model.Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	w, exists := m.webhooks[id]
+	if !exists {
+		return model.Webhook{}, ErrNoWebhookID
+	}
+
+	return w, nil
+}
+
+// DeleteWebhook removes webhook and cancels its pending deliveries.
+// Delivery history is kept.
+func (m *Mock) DeleteWebhook(id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.webhooks[id]; !exists {
+		return ErrNoWebhookID
+	}
+
+	delete(m.webhooks, id)
+
+	for dID, d := range m.deliveries {
+		if d.WebhookId == id && d.Status == model.DeliveryPending {
+			d.Status = model.DeliveryCanceled
+			d.NextAttemptAt = time.Time{}
+			m.deliveries[dID] = d
+		}
+	}
+
+	return nil
+}
+
+func (m *Mock) CreateDeliveries(ds []model.Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, d := range ds {
+		m.deliveries[d.Id] = d
+	}
+
+	return nil
+}
+
+// DueDeliveries returns pending deliveries scheduled not later than now.
+func (m *Mock) DueDeliveries(now time.Time) ([]model.Delivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	due := make([]model.Delivery, 0)
+	for _, d := range m.deliveries {
+		if d.Status == model.DeliveryPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+	})
+
+	return due, nil
+}
+
+func (m *Mock) UpdateDelivery(d model.Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.deliveries[d.Id]; !exists {
+		return ErrNoDeliveryID
+	}
+
+	// deliveries of deleted webhook stay canceled
+	if _, exists := m.webhooks[d.WebhookId]; !exists {
+		return ErrNoWebhookID
+	}
+
+	m.deliveries[d.Id] = d
+
+	return nil
+}
+
+// Deliveries returns delivery history of webhook, newest first.
+func (m *Mock) Deliveries(webhookID uuid.UUID) ([]model.Delivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ds := make([]model.Delivery, 0)
+	for _, d := range m.deliveries {
+		if d.WebhookId == webhookID {
+			ds = append(ds, d)
+		}
+	}
+
+	sort.Slice(ds, func(i, j int) bool {
+		return ds[i].CreatedAt.After(ds[j].CreatedAt)
+	})
+
+	return ds, nil
+}
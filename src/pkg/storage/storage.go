@@ -0,0 +1,47 @@
+// Package storage holds errors and interfaces shared by storage backends,
+// so callers do not depend on particular backend.
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/lekht/account-master/src/internal/model"
+)
+
+var (
+	ErrNoUserID      = errors.New("no user with this id")
+	ErrUserExists    = errors.New("user already exists")
+	ErrNoUsername    = errors.New("no user with this username")
+	ErrNoWebhookID   = errors.New("no webhook with this id")
+	ErrNoDeliveryID  = errors.New("no delivery with this id")
+	ErrNoOutboxEvent = errors.New("no outbox event with this id")
+	ErrNoIdempotency = errors.New("no idempotency record with this key")
+	ErrTxDone        = errors.New("transaction is already committed or rolled back")
+)
+
+// Tx is unit of work over users. Changes made through Tx are visible
+// to later calls of the same Tx and are applied all at once on commit.
+type Tx interface {
+	UserByID(context.Context, uuid.UUID) (model.Profile, error)
+	UserByName(context.Context, string) (model.Profile, error)
+	CreateUser(context.Context, model.Profile) (model.Profile, error)
+	UpdateUser(context.Context, uuid.UUID, model.Profile) error
+	DeleteUser(context.Context, uuid.UUID) error
+}
+
+// Repository of users implemented by storage backends. It is Tx outside
+// of unit of work plus listing of users.
+type Repository interface {
+	Tx
+	Users(context.Context) ([]model.Profile, error)
+	// UsersPage returns up to limit users with username greater than
+	// after, sorted by username.
+	UsersPage(ctx context.Context, after string, limit int) ([]model.Profile, error)
+}
+
+// Transactional is implemented by backends able to run unit of work.
+type Transactional interface {
+	WithinTx(ctx context.Context, fn func(tx Tx) error) error
+}
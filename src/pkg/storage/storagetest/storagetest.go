@@ -0,0 +1,222 @@
+// Package storagetest is conformance suite for user storage backends.
+// Backend tests call Run with constructor of empty storage.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/lekht/account-master/src/internal/model"
+	"github.com/lekht/account-master/src/pkg/storage"
+)
+
+// Run runs conformance tests. newRepo must return empty storage.
+// Backends implementing storage.Transactional are also checked for
+// unit of work.
+func Run(t *testing.T, newRepo func() storage.Repository) {
+	t.Run("CRUD", func(t *testing.T) { testCRUD(t, newRepo()) })
+	t.Run("Errors", func(t *testing.T) { testErrors(t, newRepo()) })
+	t.Run("UsersPage", func(t *testing.T) { testUsersPage(t, newRepo()) })
+	t.Run("Canceled", func(t *testing.T) { testCanceled(t, newRepo()) })
+
+	if _, ok := newRepo().(storage.Transactional); ok {
+		t.Run("Tx", func(t *testing.T) { testTx(t, newRepo()) })
+	}
+}
+
+func testCRUD(t *testing.T, repo storage.Repository) {
+	ctx := context.Background()
+
+	created, err := repo.CreateUser(ctx, model.Profile{Username: "alice", Email: "alice@example.com", Password: "hash"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	if created.Id == uuid.Nil {
+		t.Fatalf("CreateUser() returned nil id")
+	}
+
+	if got, err := repo.UserByID(ctx, created.Id); err != nil || got != created {
+		t.Errorf("UserByID() = %+v, %v, want %+v", got, err, created)
+	}
+
+	if got, err := repo.UserByName(ctx, "alice"); err != nil || got != created {
+		t.Errorf("UserByName() = %+v, %v, want %+v", got, err, created)
+	}
+
+	// update replaces whole profile, so fields can be cleared
+	upd := model.Profile{Username: "alice", Password: "hash", Admin: true}
+	if err = repo.UpdateUser(ctx, created.Id, upd); err != nil {
+		t.Fatalf("UpdateUser() error = %v", err)
+	}
+
+	upd.Id = created.Id
+	if got, _ := repo.UserByID(ctx, created.Id); got != upd {
+		t.Errorf("after UpdateUser() user = %+v, want %+v", got, upd)
+	}
+
+	if err = repo.DeleteUser(ctx, created.Id); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+
+	if users, err := repo.Users(ctx); err != nil || len(users) != 0 {
+		t.Errorf("after DeleteUser() Users() = %v, %v", users, err)
+	}
+}
+
+func testErrors(t *testing.T, repo storage.Repository) {
+	ctx := context.Background()
+
+	alice, _ := repo.CreateUser(ctx, model.Profile{Username: "alice"})
+	_, _ = repo.CreateUser(ctx, model.Profile{Username: "bob"})
+	missing := uuid.New()
+
+	tests := []struct {
+		name string
+		call func() error
+		want error
+	}{
+		{name: "create existing", call: func() error { _, err := repo.CreateUser(ctx, model.Profile{Username: "bob"}); return err }, want: storage.ErrUserExists},
+		{name: "rename to existing", call: func() error { return repo.UpdateUser(ctx, alice.Id, model.Profile{Username: "bob"}) }, want: storage.ErrUserExists},
+		{name: "get missing", call: func() error { _, err := repo.UserByID(ctx, missing); return err }, want: storage.ErrNoUserID},
+		{name: "get missing name", call: func() error { _, err := repo.UserByName(ctx, "carol"); return err }, want: storage.ErrNoUsername},
+		{name: "update missing", call: func() error { return repo.UpdateUser(ctx, missing, model.Profile{Username: "carol"}) }, want: storage.ErrNoUserID},
+		{name: "delete missing", call: func() error { return repo.DeleteUser(ctx, missing) }, want: storage.ErrNoUserID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func testUsersPage(t *testing.T, repo storage.Repository) {
+	ctx := context.Background()
+
+	for _, name := range []string{"dave", "bob", "alice", "carol", "eve"} {
+		if _, err := repo.CreateUser(ctx, model.Profile{Username: name}); err != nil {
+			t.Fatalf("CreateUser() error = %v", err)
+		}
+	}
+
+	var got []string
+	after := ""
+	for {
+		page, err := repo.UsersPage(ctx, after, 2)
+		if err != nil {
+			t.Fatalf("UsersPage() error = %v", err)
+		}
+
+		for _, u := range page {
+			got = append(got, u.Username)
+		}
+
+		if len(page) < 2 {
+			break
+		}
+		after = page[len(page)-1].Username
+	}
+
+	want := []string{"alice", "bob", "carol", "dave", "eve"}
+	if len(got) != len(want) {
+		t.Fatalf("pages = %v, want %v", got, want)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("pages = %v, want %v", got, want)
+		}
+	}
+}
+
+// testCanceled checks that every method fails with context error
+// and does no change when context is canceled.
+func testCanceled(t *testing.T, repo storage.Repository) {
+	alice, err := repo.CreateUser(context.Background(), model.Profile{Username: "alice"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{name: "Users", call: func() error { _, err := repo.Users(ctx); return err }},
+		{name: "UsersPage", call: func() error { _, err := repo.UsersPage(ctx, "", 10); return err }},
+		{name: "UserByID", call: func() error { _, err := repo.UserByID(ctx, alice.Id); return err }},
+		{name: "UserByName", call: func() error { _, err := repo.UserByName(ctx, "alice"); return err }},
+		{name: "CreateUser", call: func() error { _, err := repo.CreateUser(ctx, model.Profile{Username: "bob"}); return err }},
+		{name: "UpdateUser", call: func() error { return repo.UpdateUser(ctx, alice.Id, model.Profile{Username: "carol"}) }},
+		{name: "DeleteUser", call: func() error { return repo.DeleteUser(ctx, alice.Id) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, context.Canceled) {
+				t.Errorf("error = %v, want %v", err, context.Canceled)
+			}
+		})
+	}
+
+	users, err := repo.Users(context.Background())
+	if err != nil || len(users) != 1 || users[0] != alice {
+		t.Errorf("canceled calls changed storage: %+v, %v", users, err)
+	}
+}
+
+func testTx(t *testing.T, repo storage.Repository) {
+	errFail := errors.New("fail")
+
+	tests := []struct {
+		name   string
+		cancel bool // cancel context inside unit of work
+		fnErr  error
+		want   error
+		users  int
+	}{
+		{name: "commit", users: 2},
+		{name: "rollback", fnErr: errFail, want: errFail, users: 0},
+		{name: "canceled", cancel: true, want: context.Canceled, users: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			err := repo.(storage.Transactional).WithinTx(ctx, func(tx storage.Tx) error {
+				for _, name := range []string{"tx-alice", "tx-bob"} {
+					if _, err := tx.CreateUser(ctx, model.Profile{Username: name}); err != nil {
+						return err
+					}
+				}
+
+				if tt.cancel {
+					cancel()
+				}
+
+				return tt.fnErr
+			})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("WithinTx() error = %v, want %v", err, tt.want)
+			}
+
+			users, _ := repo.Users(context.Background())
+			if len(users) != tt.users {
+				t.Errorf("users = %d, want %d", len(users), tt.users)
+			}
+
+			for _, u := range users {
+				_ = repo.DeleteUser(context.Background(), u.Id)
+			}
+		})
+	}
+}